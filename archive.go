@@ -0,0 +1,91 @@
+package lumberjack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// osRename exists so it can be mocked out by tests.
+var osRename = os.Rename
+
+// backupDir returns the directory backups are kept in, the archive directory
+// if one is set and the directory of the log file otherwise.
+func (r *Roller) backupDir() string {
+	if r.archiveDir == "" {
+		return r.dir()
+	}
+	if filepath.IsAbs(r.archiveDir) {
+		return r.archiveDir
+	}
+	return filepath.Join(r.dir(), r.archiveDir)
+}
+
+// archivedName returns the name of the log file moved into the backup
+// directory, which backup names are derived from.
+func (r *Roller) archivedName() string {
+	return filepath.Join(r.backupDir(), filepath.Base(r.newFilename()))
+}
+
+// moveFile moves src to dst. If they are on different filesystems, which
+// rename can't cross, src is copied to dst and then removed.
+func moveFile(src, dst string) error {
+	err := osRename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile copies src to dst with its mode and modification time. The copy is
+// written to a temporary file and synced before it is renamed to dst, so that
+// dst is complete if it exists.
+func copyFile(src, dst string) (err error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := dst + tmpSuffix
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(tmp)
+			err = fmt.Errorf("failed to copy %s to %s: %v", src, dst, err)
+		}
+	}()
+
+	if _, err := io.Copy(out, f); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp, fi.ModTime(), fi.ModTime()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(dst))
+	return nil
+}
@@ -0,0 +1,206 @@
+package lumberjack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestArchiveDir(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestArchiveDir", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	archive := filepath.Join(dir, "archive")
+	l, err := NewRoller(filename, &Options{ArchiveDir: "archive", MaxBackups: 1, MaxSize: 10})
+	isNil(err, t)
+	defer l.Close()
+
+	b := []byte("boo!")
+	_, err = l.Write(b)
+	isNil(err, t)
+
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	first := filepath.Join(archive, filepath.Base(backupFile(dir)))
+	existsWithContent(first, b, t)
+	existsWithContent(filename, []byte{}, t)
+
+	b2 := []byte("foooooo!")
+	_, err = l.Write(b2)
+	isNil(err, t)
+
+	newFakeTime()
+	isNil(l.Rotate(), t)
+
+	// we need to wait a little bit since the files get removed on a different
+	// goroutine.
+	<-time.After(10 * time.Millisecond)
+
+	// retention only looks at the archive directory
+	existsWithContent(filepath.Join(archive, filepath.Base(backupFile(dir))), b2, t)
+	notExist(first, t)
+	fileCount(archive, 1, t)
+	// the log file and the archive directory
+	fileCount(dir, 2, t)
+}
+
+func TestArchiveDirCrossDevice(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestArchiveDirCrossDevice", t)
+	defer os.RemoveAll(dir)
+	archive := makeTempDir("TestArchiveDirCrossDeviceArchive", t)
+	defer os.RemoveAll(archive)
+
+	osRename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	defer func() { osRename = os.Rename }()
+
+	filename := logFile(dir)
+	b := []byte("boo!")
+	isNil(ioutil.WriteFile(filename, b, 0600), t)
+
+	l, err := NewRoller(filename, &Options{ArchiveDir: archive, MaxSize: 10})
+	isNil(err, t)
+	defer l.Close()
+
+	newFakeTime()
+	isNil(l.Rotate(), t)
+
+	backup := filepath.Join(archive, filepath.Base(backupFile(dir)))
+	existsWithContent(backup, b, t)
+	info, err := os.Stat(backup)
+	isNil(err, t)
+	equals(os.FileMode(0600), info.Mode(), t)
+	existsWithContent(filename, []byte{}, t)
+	fileCount(dir, 1, t)
+}
+
+func TestPartitionLayout(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	dir := makeTempDir("TestPartitionLayout", t)
+	defer os.RemoveAll(dir)
+
+	_, err := NewRoller(logFile(dir), &Options{PartitionLayout: "../2006"})
+	notNil(err, t)
+
+	// a backup from before partitioning was enabled
+	archive := filepath.Join(dir, "archive")
+	isNil(os.MkdirAll(archive, 0755), t)
+	old := filepath.Join(archive, "foobar-20261001.log")
+	isNil(ioutil.WriteFile(old, []byte("old"), 0644), t)
+
+	filename := logFile(dir)
+	r, err := NewRoller(filename, &Options{
+		MaxBackups:      2,
+		RotateType:      RotateDaily,
+		ArchiveDir:      "archive",
+		PartitionLayout: "2006/01/02",
+	})
+	isNil(err, t)
+	defer r.Close()
+
+	b := []byte("boo!")
+	for i := 0; i < 2; i++ {
+		_, err := r.Write(b)
+		isNil(err, t)
+		newFakeTime(24 * time.Hour)
+	}
+	_, err = r.Write(b)
+	isNil(err, t)
+
+	// we need to wait a little bit since the files get deleted on a different
+	// goroutine.
+	<-time.After(10 * time.Millisecond)
+
+	existsWithContent(filepath.Join(archive, "2026", "10", "18", "foobar-20261018.log"), b, t)
+	existsWithContent(filepath.Join(archive, "2026", "10", "19", "foobar-20261019.log"), b, t)
+	notExist(old, t)
+
+	newFakeTime(24 * time.Hour)
+	_, err = r.Write(b)
+	isNil(err, t)
+	<-time.After(10 * time.Millisecond)
+
+	// the emptied directory is removed, its parents are still in use
+	notExist(filepath.Join(archive, "2026", "10", "18"), t)
+	existsWithContent(filepath.Join(archive, "2026", "10", "20", "foobar-20261020.log"), b, t)
+
+	files, err := r.oldLogFiles()
+	isNil(err, t)
+	equals(2, len(files), t)
+}
+
+func TestPartitionLayoutForeignFiles(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	dir := makeTempDir("TestPartitionLayoutForeignFiles", t)
+	defer os.RemoveAll(dir)
+
+	// files in the log directory which only look like backups
+	foreign := []string{
+		filepath.Join(dir, "other", "app", "v1", "foobar-20200101000000.log"),
+		filepath.Join(dir, "2020", "01", "02", "foobar-20200101000000.log"),
+	}
+	for _, f := range foreign {
+		isNil(os.MkdirAll(filepath.Dir(f), 0755), t)
+		isNil(ioutil.WriteFile(f, []byte("other"), 0644), t)
+	}
+
+	r, err := NewRoller(logFile(dir), &Options{MaxBackups: 1, PartitionLayout: "2006/01/02"})
+	isNil(err, t)
+	defer r.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err := r.Write([]byte("boo!"))
+		isNil(err, t)
+		newFakeTime()
+		isNil(r.Rotate(), t)
+	}
+	isNil(r.millRunOnce(), t)
+
+	for _, f := range foreign {
+		existsWithContent(f, []byte("other"), t)
+	}
+	files, err := r.oldLogFiles()
+	isNil(err, t)
+	equals(1, len(files), t)
+	equals(filepath.Join(dir, files[0].timestamp.Format("2006/01/02")), filepath.Dir(files[0].path), t)
+}
+
+func TestPartitionLayoutWalk(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	dir := makeTempDir("TestPartitionLayoutWalk", t)
+	defer os.RemoveAll(dir)
+
+	backup := filepath.Join(dir, "2026", "10", "18", "foobar-20261018100000.log")
+	old := filepath.Join(dir, "foobar-20261017100000.log")
+	// not partitions, or deeper than them, so never looked into
+	skipped := []string{
+		filepath.Join(dir, "private", "secret"),
+		filepath.Join(dir, "2026", "10", "18", "deeper", "foobar-20261018100000.log"),
+		filepath.Join(dir, "20x6", "10", "18", "foobar-20261018100000.log"),
+	}
+	for _, f := range append(skipped, backup, old) {
+		isNil(os.MkdirAll(filepath.Dir(f), 0755), t)
+		isNil(ioutil.WriteFile(f, []byte("data"), 0644), t)
+	}
+
+	r, err := NewRoller(logFile(dir), &Options{PartitionLayout: "2006/01/02"})
+	isNil(err, t)
+	defer r.Close()
+
+	var walked []string
+	isNil(r.walkBackupDir(func(path string, _ os.FileInfo) error {
+		walked = append(walked, path)
+		return nil
+	}), t)
+	equals([]string{backup, old, logFile(dir)}, walked, t)
+}
@@ -0,0 +1,409 @@
+package lumberjack
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBufferSize is the size of the buffer of asynchronous writes.
+	defaultBufferSize = 256 * 1024
+	// defaultFlushInterval is how often asynchronous writes are written to the
+	// log file.
+	defaultFlushInterval = time.Second
+	// defaultSampleRate is the rate OverflowSample keeps records at.
+	defaultSampleRate = 10
+)
+
+// OverflowPolicy is what a Roller does with asynchronous writes while its
+// buffer is full.
+type OverflowPolicy string
+
+var (
+	// OverflowBlock blocks the writes until there is room in the buffer.
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropNewest drops the writes, which return ErrBufferFull.
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+	// OverflowDropOldest drops the oldest buffered records to make room.
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	// OverflowSample keeps one in Options.SampleRate of the writes, blocking
+	// until there is room for it, and drops the others, which return
+	// ErrBufferFull.
+	OverflowSample OverflowPolicy = "sample"
+)
+
+func IsLegalOverflowPolicy(p OverflowPolicy) bool {
+	return p == "" || p == OverflowBlock || p == OverflowDropNewest || p == OverflowDropOldest || p == OverflowSample
+}
+
+// ErrBufferFull is returned by asynchronous writes dropped because the buffer
+// is full.
+const ErrBufferFull = constError("write buffer full")
+
+// ring is a fixed size ring buffer of records.
+type ring struct {
+	buf []byte
+	// head is where the oldest record starts and n the number of bytes
+	// buffered.
+	head, n int
+	// lens are the lengths of the records, oldest first.
+	lens []int
+}
+
+// dropOldest removes the oldest record, returning its length.
+func (b *ring) dropOldest() int {
+	l := b.lens[0]
+	b.lens = b.lens[1:]
+	b.head = (b.head + l) % len(b.buf)
+	b.n -= l
+	return l
+}
+
+// free returns the number of bytes that can still be pushed.
+func (b *ring) free() int {
+	return len(b.buf) - b.n
+}
+
+// push appends the record p, which must fit.
+func (b *ring) push(p []byte) {
+	tail := (b.head + b.n) % len(b.buf)
+	c := copy(b.buf[tail:], p)
+	copy(b.buf, p[c:])
+	b.n += len(p)
+	b.lens = append(b.lens, len(p))
+}
+
+// popAll removes all the records, appending them to data and their lengths to
+// lens.
+func (b *ring) popAll(data []byte, lens []int) ([]byte, []int) {
+	end := b.head + b.n
+	if end <= len(b.buf) {
+		data = append(data, b.buf[b.head:end]...)
+	} else {
+		data = append(data, b.buf[b.head:]...)
+		data = append(data, b.buf[:end-len(b.buf)]...)
+	}
+	lens = append(lens, b.lens...)
+	b.head, b.n, b.lens = 0, 0, b.lens[:0]
+	return data, lens
+}
+
+// asyncWriter buffers the writes to a Roller in memory, for a background
+// goroutine to write them to the log file.
+type asyncWriter struct {
+	mu sync.Mutex
+	// cond is signalled when buffered records have been written.
+	cond *sync.Cond
+	ring ring
+	// accepted and flushed are the number of bytes accepted by Write and
+	// written to the log file so far.
+	accepted, flushed int64
+	// err is the first error writing to the log file not yet returned.
+	err    error
+	closed bool
+
+	overflow   OverflowPolicy
+	sampleRate int
+	// overflows counts the writes which didn't fit in the buffer, for
+	// sampling.
+	overflows int
+	// dropped counts the records and bytes dropped so far, and unmarked
+	// those not yet noted in the log file by a marker, last written at
+	// markedAt.
+	dropped, unmarked dropCount
+	markedAt          time.Time
+
+	interval time.Duration
+	kick     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	// data and lens are reused by the drain goroutine.
+	data []byte
+	lens []int
+}
+
+// dropCount is a number of records and their bytes dropped.
+type dropCount struct {
+	records, bytes int64
+}
+
+func (c *dropCount) add(bytes int) {
+	c.records++
+	c.bytes += int64(bytes)
+}
+
+// startAsync makes writes go through a buffer of size bytes, which is written
+// to the log file every interval, or sooner when it fills up. While it is full,
+// writes are handled by overflow.
+func (r *Roller) startAsync(size int, interval time.Duration, overflow OverflowPolicy, sampleRate int) {
+	r.async = newAsyncWriter(size, interval, overflow, sampleRate)
+	go r.runDrain()
+}
+
+// newAsyncWriter returns an asyncWriter, applying the defaults.
+func newAsyncWriter(size int, interval time.Duration, overflow OverflowPolicy, sampleRate int) *asyncWriter {
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	if overflow == "" {
+		overflow = OverflowBlock
+	}
+	if sampleRate <= 0 {
+		sampleRate = defaultSampleRate
+	}
+	a := &asyncWriter{
+		ring:       ring{buf: make([]byte, size)},
+		overflow:   overflow,
+		sampleRate: sampleRate,
+		markedAt:   currentTime(),
+		interval:   interval,
+		kick:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	a.cond = sync.NewCond(&a.mu)
+	return a
+}
+
+// stopAsync writes the buffered records to the log file and stops the drain
+// goroutine, if writes are asynchronous. Later writes fail. It must not be
+// called with r.mu held.
+func (r *Roller) stopAsync() error {
+	a := r.async
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	a.closed = true
+	a.mu.Unlock()
+	a.stopOnce.Do(func() {
+		close(a.stop)
+	})
+	<-a.done
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.takeErr()
+}
+
+// Dropped returns the number of records, and their bytes, dropped so far
+// because the buffer of asynchronous writes was full.
+func (r *Roller) Dropped() (records, bytes int64) {
+	a := r.async
+	if a == nil {
+		return 0, 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.dropped.records, a.dropped.bytes
+}
+
+// writeAsync buffers p to be written to the log file by the drain goroutine.
+// A record larger than the whole buffer is written directly, once everything
+// before it has been, unless the overflow policy is not to block.
+func (r *Roller) writeAsync(p []byte) (int, error) {
+	a := r.async
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return 0, os.ErrClosed
+	}
+	if err := a.takeErr(); err != nil {
+		return 0, err
+	}
+	if len(p) > len(a.ring.buf) {
+		if a.overflow != OverflowBlock {
+			a.drop(len(p))
+			return 0, ErrBufferFull
+		}
+		a.wait(a.accepted)
+		r.mu.Lock()
+		n, err := r.write(p)
+		r.mu.Unlock()
+		a.accepted += int64(n)
+		a.flushed += int64(n)
+		return n, err
+	}
+	if a.ring.free() < len(p) {
+		a.wake()
+		switch a.overflow {
+		case OverflowDropNewest:
+			a.drop(len(p))
+			return 0, ErrBufferFull
+		case OverflowDropOldest:
+			for a.ring.free() < len(p) {
+				n := a.ring.dropOldest()
+				// as good as written, for those waiting on it
+				a.flushed += int64(n)
+				a.drop(n)
+			}
+		case OverflowSample:
+			a.overflows++
+			if (a.overflows-1)%a.sampleRate != 0 {
+				a.drop(len(p))
+				return 0, ErrBufferFull
+			}
+		}
+	}
+	for a.ring.free() < len(p) {
+		a.wake()
+		a.cond.Wait()
+		if a.closed {
+			return 0, os.ErrClosed
+		}
+	}
+	a.ring.push(p)
+	a.accepted += int64(len(p))
+	if a.ring.n >= len(a.ring.buf)/2 {
+		a.wake()
+	}
+	return len(p), nil
+}
+
+// flush waits until everything accepted so far has been written to the log
+// file, if writes are asynchronous. It must not be called with r.mu held.
+func (r *Roller) flush() {
+	a := r.async
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.wait(a.accepted)
+}
+
+// flushErr is flush, returning the error of an earlier asynchronous write,
+// if any.
+func (r *Roller) flushErr() error {
+	a := r.async
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.wait(a.accepted)
+	return a.takeErr()
+}
+
+// runDrain writes the buffered records to the log file every interval, or when
+// woken up, until stopped.
+func (r *Roller) runDrain() {
+	a := r.async
+	defer close(a.done)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			r.drain(true)
+			return
+		case <-a.kick:
+		case <-ticker.C:
+		}
+		r.drain(false)
+	}
+}
+
+// drain writes the buffered records to the log file. If records were dropped,
+// a marker noting how many is written before them, at most once an interval,
+// or when stopping.
+func (r *Roller) drain(stopping bool) {
+	a := r.async
+	a.mu.Lock()
+	var marker string
+	now := currentTime()
+	if a.unmarked.records > 0 && (stopping || now.Sub(a.markedAt) >= a.interval) {
+		marker = fmt.Sprintf("lumberjack: %d records (%d bytes) dropped, write buffer full",
+			a.unmarked.records, a.unmarked.bytes)
+		a.unmarked = dropCount{}
+		a.markedAt = now
+	}
+	a.data, a.lens = a.ring.popAll(a.data[:0], a.lens[:0])
+	a.mu.Unlock()
+	if len(a.data) == 0 && marker == "" {
+		return
+	}
+
+	r.mu.Lock()
+	var err error
+	if marker != "" {
+		err = r.writeMarker(marker)
+	}
+	if err == nil && len(a.data) > 0 {
+		if len(r.delimiter) > 0 {
+			// the records may be split across writes
+			_, err = r.write(a.data)
+		} else {
+			err = r.writeRecords(a.data, a.lens)
+		}
+	}
+	r.mu.Unlock()
+
+	a.mu.Lock()
+	a.flushed += int64(len(a.data))
+	if err != nil && a.err == nil {
+		a.err = err
+	}
+	a.cond.Broadcast()
+	a.mu.Unlock()
+}
+
+// writeRecords writes the records, concatenated in data, to the log file,
+// rotating between them as needed. Consecutive records which don't need a
+// rotation between them, and together aren't longer than maxWrite, are
+// written at once. The caller holds r.mu.
+func (r *Roller) writeRecords(data []byte, lens []int) error {
+	start, end := 0, 0
+	for _, l := range lens {
+		if end > start {
+			s := r.state(int64(l))
+			s.Size += int64(end - start)
+			if r.policy.ShouldRotate(s) || r.maxWrite > 0 && int64(end-start+l) > r.maxWrite {
+				if _, err := r.writeWhole(data[start:end]); err != nil {
+					return err
+				}
+				start = end
+			}
+		}
+		end += l
+	}
+	_, err := r.writeWhole(data[start:end])
+	return err
+}
+
+// wait waits until the first n bytes accepted have been written to the log
+// file. The caller holds a.mu.
+func (a *asyncWriter) wait(n int64) {
+	for a.flushed < n {
+		a.wake()
+		a.cond.Wait()
+	}
+}
+
+// drop counts a record of n bytes as dropped. The caller holds a.mu.
+func (a *asyncWriter) drop(n int) {
+	a.dropped.add(n)
+	a.unmarked.add(n)
+}
+
+// wake wakes up the drain goroutine, unless it already has been.
+func (a *asyncWriter) wake() {
+	select {
+	case a.kick <- struct{}{}:
+	default:
+	}
+}
+
+// takeErr returns and clears the error of an earlier write. The caller holds
+// a.mu.
+func (a *asyncWriter) takeErr() error {
+	err := a.err
+	a.err = nil
+	return err
+}
@@ -0,0 +1,201 @@
+package lumberjack
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAsync(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestAsync", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{Async: true, FlushInterval: time.Hour})
+	isNil(err, t)
+	defer l.Close()
+
+	b := []byte("boo!")
+	n, err := l.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	// the write is only buffered
+	existsWithContent(filename, []byte{}, t)
+
+	isNil(l.Sync(), t)
+	existsWithContent(filename, b, t)
+
+	n, err = l.Write([]byte("foo!"))
+	isNil(err, t)
+	equals(4, n, t)
+	isNil(l.Close(), t)
+	existsWithContent(filename, []byte("boo!foo!"), t)
+
+	_, err = l.Write(b)
+	equals(os.ErrClosed, err, t)
+}
+
+func TestAsyncRotate(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestAsyncRotate", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{Async: true, FlushInterval: time.Hour, MaxSize: 10})
+	isNil(err, t)
+	defer l.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err := l.Write([]byte("boo!"))
+		isNil(err, t)
+	}
+	newFakeTime()
+	isNil(l.Sync(), t)
+
+	// the rotation is decided as the buffer is written out
+	existsWithContent(backupFile(dir), []byte("boo!boo!"), t)
+	existsWithContent(filename, []byte("boo!"), t)
+
+	_, err = l.Write([]byte("foo!"))
+	isNil(err, t)
+	newFakeTime()
+	// buffered writes go to the log file being rotated
+	isNil(l.Rotate(), t)
+	existsWithContent(backupFile(dir), []byte("boo!foo!"), t)
+	existsWithContent(filename, []byte{}, t)
+}
+
+func TestAsyncBufferFull(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestAsyncBufferFull", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{Async: true, FlushInterval: time.Hour, BufferSize: 8})
+	isNil(err, t)
+	defer l.Close()
+
+	// a full buffer is written out to make room
+	for i := 0; i < 5; i++ {
+		_, err := l.Write([]byte("boo!"))
+		isNil(err, t)
+	}
+	// a write larger than the buffer is written directly, after the buffer
+	_, err = l.Write([]byte("foooooooooo!"))
+	isNil(err, t)
+	existsWithContent(filename, []byte("boo!boo!boo!boo!boo!foooooooooo!"), t)
+}
+
+func TestRing(t *testing.T) {
+	b := ring{buf: make([]byte, 8)}
+	b.push([]byte("abcde"))
+	data, lens := b.popAll(nil, nil)
+	equals("abcde", string(data), t)
+	equals([]int{5}, lens, t)
+
+	b.head = 6
+	b.push([]byte("fgh"))
+	b.push([]byte("ij"))
+	equals(3, b.free(), t)
+	data, lens = b.popAll(data[:0], lens[:0])
+	equals("fghij", string(data), t)
+	equals([]int{3, 2}, lens, t)
+	equals(8, b.free(), t)
+}
+
+func TestAsyncOverflow(t *testing.T) {
+	currentTime = fakeTime
+	tests := []struct {
+		overflow OverflowPolicy
+		writes   []string
+		errs     int
+		dropped  int64
+		want     string
+	}{
+		{OverflowDropNewest, []string{"a1", "b2", "c3", "d4", "e5"}, 3, 3, dropMarker(3, 6) + "a1b2"},
+		{OverflowDropOldest, []string{"a1", "b2", "c3", "d4", "e5"}, 0, 3, dropMarker(3, 6) + "d4e5"},
+		{OverflowDropNewest, []string{"a1", "too long"}, 1, 1, dropMarker(1, 8) + "a1"},
+	}
+	for i, test := range tests {
+		dir := makeTempDir("TestAsyncOverflow"+strconv.Itoa(i), t)
+		defer os.RemoveAll(dir)
+		filename := logFile(dir)
+		l, err := NewRoller(filename, &Options{})
+		isNil(err, t)
+		// nothing drains the buffer until Close
+		l.async = newAsyncWriter(4, time.Hour, test.overflow, 0)
+
+		errs := 0
+		for _, w := range test.writes {
+			if _, err := l.Write([]byte(w)); err != nil {
+				equals(ErrBufferFull, err, t)
+				errs++
+			}
+		}
+		equals(test.errs, errs, t)
+		records, _ := l.Dropped()
+		equals(test.dropped, records, t)
+
+		select {
+		case <-l.async.kick:
+		default:
+		}
+		go l.runDrain()
+		isNil(l.Close(), t)
+		existsWithContent(filename, []byte(test.want), t)
+	}
+}
+
+func TestAsyncOverflowSample(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestAsyncOverflowSample", t)
+	defer os.RemoveAll(dir)
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{})
+	isNil(err, t)
+	// nothing drains the buffer until told to
+	l.async = newAsyncWriter(4, time.Hour, OverflowSample, 2)
+
+	for _, w := range []string{"a1", "b2"} {
+		_, err := l.Write([]byte(w))
+		isNil(err, t)
+	}
+	// the first of two writes overflowing waits for room, the second is
+	// dropped
+	done := make(chan error)
+	go func() {
+		_, err := l.Write([]byte("c3"))
+		done <- err
+	}()
+	for {
+		l.async.mu.Lock()
+		overflows := l.async.overflows
+		l.async.mu.Unlock()
+		if overflows == 1 {
+			break
+		}
+		<-time.After(time.Millisecond)
+	}
+	_, err = l.Write([]byte("d4"))
+	equals(ErrBufferFull, err, t)
+
+	l.drain(false)
+	isNil(<-done, t)
+	records, bytes := l.Dropped()
+	equals(int64(1), records, t)
+	equals(int64(2), bytes, t)
+
+	select {
+	case <-l.async.kick:
+	default:
+	}
+	go l.runDrain()
+	isNil(l.Close(), t)
+	existsWithContent(filename, []byte("a1b2"+dropMarker(1, 2)+"c3"), t)
+}
+
+func dropMarker(records, bytes int) string {
+	return "lumberjack: " + strconv.Itoa(records) + " records (" + strconv.Itoa(bytes) + " bytes) dropped, write buffer full\n"
+}
@@ -0,0 +1,303 @@
+package lumberjack
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// backupLayouts are the time layouts a Roller has ever used for the timestamp
+// in backup names. Backups in any of them are recognized, so that retention
+// and compression still apply after the rotation settings change.
+var backupLayouts = []string{
+	backupTimeFormat,          // rotation by size or minute
+	"20060102-15",             // hourly
+	"20060102",                // daily and weekly
+	"200601",                  // monthly
+	"2006-01-02T15-04-05.000", // lumberjack v2
+}
+
+// backupCodec formats and parses the timestamp part of backup names, with an
+// optional sequence number "-N" for backups of the same period.
+type backupCodec struct {
+	// layout is the time layout of new backups, and the one preferred when a
+	// name could be read in several.
+	layout string
+	loc    *time.Location
+}
+
+// format returns the timestamp part of a backup name, seq 0 meaning none.
+func (c backupCodec) format(t time.Time, seq int) string {
+	ts := t.In(c.loc).Format(c.layout)
+	if seq > 0 {
+		ts += "-" + strconv.Itoa(seq)
+	}
+	return ts
+}
+
+// parse extracts the time and sequence number from the timestamp part of a
+// backup name.
+func (c backupCodec) parse(ts string) (time.Time, int, error) {
+	if t, seq, ok := c.parseLayout(c.layout, ts); ok {
+		return t, seq, nil
+	}
+	for _, layout := range backupLayouts {
+		if layout == c.layout {
+			continue
+		}
+		if t, seq, ok := c.parseLayout(layout, ts); ok {
+			return t, seq, nil
+		}
+	}
+	return time.Time{}, 0, fmt.Errorf("%q is not a backup timestamp", ts)
+}
+
+// parseLayout parses ts in the given layout, with or without a sequence
+// number. Only exact renderings of the layout are accepted, so that e.g. a
+// daily backup with a sequence number isn't taken for an hourly one.
+func (c backupCodec) parseLayout(layout, ts string) (time.Time, int, bool) {
+	if t, err := time.ParseInLocation(layout, ts, c.loc); err == nil && t.Format(layout) == ts {
+		return t, 0, true
+	}
+	i := strings.LastIndex(ts, "-")
+	if i < 0 {
+		return time.Time{}, 0, false
+	}
+	seq, err := strconv.Atoi(ts[i+1:])
+	if err != nil || seq <= 0 || ts[i+1] == '0' {
+		return time.Time{}, 0, false
+	}
+	t, err := time.ParseInLocation(layout, ts[:i], c.loc)
+	if err != nil || t.Format(layout) != ts[:i] {
+		return time.Time{}, 0, false
+	}
+	return t, seq, true
+}
+
+// backupTemplate is a parsed Options.BackupNameTemplate. It formats backup
+// names, relative to the log file's directory, and parses them back.
+type backupTemplate struct {
+	parts  []templatePart
+	re     *regexp.Regexp
+	layout string
+	loc    *time.Location
+	// hasSeq records whether the template places {seq} itself; otherwise
+	// the sequence number is appended to the name.
+	hasSeq bool
+	// groups maps the submatches of re to the token they capture.
+	groups []string
+	// dirs match the names of the directories backups are nested in, one
+	// for each level.
+	dirs []*regexp.Regexp
+}
+
+// templatePart is a literal string or a token of a backup name template.
+type templatePart struct {
+	literal string
+	token   string
+}
+
+// parseBackupTemplate parses a backup name template for the log file name
+// base + ext. layout is the time layout used by {time} without one.
+func parseBackupTemplate(tmpl, base, ext, layout string, loc *time.Location) (*backupTemplate, error) {
+	b := &backupTemplate{layout: layout, loc: loc}
+	var expr strings.Builder
+	expr.WriteString("^")
+	// dir is the pattern of the current directory name, pieces of which are
+	// split off by slashes
+	var dir strings.Builder
+	var dirs []string
+	split := func(s string, pattern func(string) string) {
+		for i, piece := range strings.Split(s, "/") {
+			if i > 0 {
+				dirs = append(dirs, dir.String())
+				dir.Reset()
+			}
+			dir.WriteString(pattern(piece))
+		}
+	}
+	times := 0
+	for rest := tmpl; rest != ""; {
+		i := strings.Index(rest, "{")
+		if i < 0 {
+			i = len(rest)
+		}
+		if i > 0 {
+			b.parts = append(b.parts, templatePart{literal: rest[:i]})
+			expr.WriteString(regexp.QuoteMeta(rest[:i]))
+			split(rest[:i], regexp.QuoteMeta)
+			rest = rest[i:]
+			continue
+		}
+		j := strings.Index(rest, "}")
+		if j < 0 {
+			return nil, fmt.Errorf("unterminated token in backup name template %q", tmpl)
+		}
+		token := rest[1:j]
+		rest = rest[j+1:]
+		switch {
+		case token == "prefix":
+			b.parts = append(b.parts, templatePart{literal: base})
+			expr.WriteString(regexp.QuoteMeta(base))
+			dir.WriteString(regexp.QuoteMeta(base))
+			continue
+		case token == "ext":
+			b.parts = append(b.parts, templatePart{literal: ext})
+			expr.WriteString(regexp.QuoteMeta(ext))
+			dir.WriteString(regexp.QuoteMeta(ext))
+			continue
+		case token == "time" || strings.HasPrefix(token, "time:"):
+			if token != "time" {
+				b.layout = token[len("time:"):]
+			}
+			times++
+			expr.WriteString("(" + layoutPattern(b.layout) + ")")
+			split(b.layout, layoutPattern)
+			token = "time"
+		case token == "seq":
+			b.hasSeq = true
+			expr.WriteString(`(?:-([1-9][0-9]*))?`)
+			dir.WriteString(`(?:-[1-9][0-9]*)?`)
+		case token == "host":
+			expr.WriteString(`([^/]+?)`)
+			dir.WriteString(`[^/]+?`)
+		case token == "pid":
+			expr.WriteString(`([0-9]+)`)
+			dir.WriteString(`[0-9]+`)
+		default:
+			return nil, fmt.Errorf("unknown token {%s} in backup name template %q", token, tmpl)
+		}
+		b.parts = append(b.parts, templatePart{token: token})
+		b.groups = append(b.groups, token)
+	}
+	if times != 1 {
+		return nil, fmt.Errorf("backup name template %q must have exactly one {time} token", tmpl)
+	}
+	if !b.hasSeq {
+		expr.WriteString(`(?:-([1-9][0-9]*))?`)
+		b.groups = append(b.groups, "seq")
+	}
+	expr.WriteString("$")
+	if strings.HasPrefix(tmpl, "/") || strings.Contains("/"+tmpl+"/", "/../") {
+		return nil, fmt.Errorf("backup name template %q must stay inside the log directory", tmpl)
+	}
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup name template %q: %w", tmpl, err)
+	}
+	b.re = re
+	for _, d := range dirs {
+		re, err := regexp.Compile("^" + d + "$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid backup name template %q: %w", tmpl, err)
+		}
+		b.dirs = append(b.dirs, re)
+	}
+	return b, nil
+}
+
+// layoutPattern returns a regular expression loosely matching times in the
+// given layout: runs of digits and letters match runs of the same kind.
+func layoutPattern(layout string) string {
+	var expr strings.Builder
+	for i := 0; i < len(layout); {
+		c := layout[i]
+		j := i + 1
+		switch {
+		case c >= '0' && c <= '9':
+			for j < len(layout) && layout[j] >= '0' && layout[j] <= '9' {
+				j++
+			}
+			expr.WriteString(`[0-9]+`)
+		case c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			for j < len(layout) && (layout[j] >= 'A' && layout[j] <= 'Z' || layout[j] >= 'a' && layout[j] <= 'z') {
+				j++
+			}
+			expr.WriteString(`[A-Za-z]+`)
+		default:
+			expr.WriteString(regexp.QuoteMeta(layout[i:j]))
+		}
+		i = j
+	}
+	return expr.String()
+}
+
+// layoutDirs returns patterns loosely matching the names of the directories
+// times in the given layout are split into by its slashes.
+func layoutDirs(layout string) []*regexp.Regexp {
+	var dirs []*regexp.Regexp
+	for _, piece := range strings.Split(layout, "/") {
+		dirs = append(dirs, regexp.MustCompile("^"+layoutPattern(piece)+"$"))
+	}
+	return dirs
+}
+
+// nested reports whether backups are placed in subdirectories.
+func (b *backupTemplate) nested() bool {
+	for _, p := range b.parts {
+		if strings.Contains(p.literal, "/") {
+			return true
+		}
+	}
+	return strings.Contains(b.layout, "/")
+}
+
+// format returns the name of a backup stamped t with sequence number seq, 0
+// meaning none, using slashes to separate directories.
+func (b *backupTemplate) format(t time.Time, seq int) string {
+	var name strings.Builder
+	for _, p := range b.parts {
+		switch p.token {
+		case "":
+			name.WriteString(p.literal)
+		case "time":
+			name.WriteString(t.In(b.loc).Format(b.layout))
+		case "seq":
+			if seq > 0 {
+				name.WriteString("-" + strconv.Itoa(seq))
+			}
+		case "host":
+			host, err := os.Hostname()
+			if err != nil {
+				host = "localhost"
+			}
+			name.WriteString(host)
+		case "pid":
+			name.WriteString(strconv.Itoa(os.Getpid()))
+		}
+	}
+	if !b.hasSeq && seq > 0 {
+		name.WriteString("-" + strconv.Itoa(seq))
+	}
+	return name.String()
+}
+
+// parse extracts the time and sequence number from the name of a backup,
+// relative to the log file's directory and using slashes.
+func (b *backupTemplate) parse(name string) (time.Time, int, error) {
+	m := b.re.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, 0, fmt.Errorf("%q doesn't match the backup name template", name)
+	}
+	var t time.Time
+	seq := 0
+	for i, token := range b.groups {
+		v := m[i+1]
+		switch token {
+		case "time":
+			var err error
+			t, err = time.ParseInLocation(b.layout, v, b.loc)
+			if err != nil || t.Format(b.layout) != v {
+				return time.Time{}, 0, fmt.Errorf("%q is not a backup timestamp", v)
+			}
+		case "seq":
+			if v != "" {
+				seq, _ = strconv.Atoi(v)
+			}
+		}
+	}
+	return t, seq, nil
+}
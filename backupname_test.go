@@ -0,0 +1,111 @@
+package lumberjack
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBackupTemplate(t *testing.T) {
+	ts := time.Date(2026, 10, 18, 14, 30, 5, 0, time.UTC)
+	host, err := os.Hostname()
+	isNil(err, t)
+	pid := strconv.Itoa(os.Getpid())
+
+	tests := []struct {
+		tmpl string
+		seq  int
+		want string
+	}{
+		{"{prefix}-{time}{seq}{ext}", 0, "foo-20261018143005.log"},
+		{"{prefix}-{time}{seq}{ext}", 2, "foo-20261018143005-2.log"},
+		{"{prefix}{ext}.{time:2006-01-02}", 0, "foo.log.2026-10-18"},
+		{"{prefix}{ext}.{time:2006-01-02}", 1, "foo.log.2026-10-18-1"},
+		{"{time:2006/01/02}/{prefix}{ext}", 0, "2026/10/18/foo.log"},
+		{"{time:2006/01/02}/{prefix}{seq}{ext}", 3, "2026/10/18/foo-3.log"},
+		{"{prefix}.{host}.{pid}.{time:Jan-02-15h}{ext}", 0, "foo." + host + "." + pid + ".Oct-18-14h.log"},
+	}
+	for _, test := range tests {
+		b, err := parseBackupTemplate(test.tmpl, "foo", ".log", backupTimeFormat, time.UTC)
+		isNil(err, t)
+		got := b.format(ts, test.seq)
+		equals(test.want, got, t)
+
+		parsed, seq, err := b.parse(got)
+		isNil(err, t)
+		equals(test.seq, seq, t)
+		layout := b.layout
+		equals(ts.Format(layout), parsed.Format(layout), t)
+	}
+
+	b, err := parseBackupTemplate("{prefix}.{host}.{pid}.{time:20060102}{ext}", "foo", ".log", backupTimeFormat, time.UTC)
+	isNil(err, t)
+	// backups of other hosts and processes are recognized
+	_, _, err = b.parse("foo.other-host.1.20261018.log")
+	isNil(err, t)
+	_, _, err = b.parse("foo.other-host.1.20261018.txt")
+	notNil(err, t)
+	_, _, err = b.parse("foo.other-host.x.20261018.log")
+	notNil(err, t)
+	_, _, err = b.parse("foo.other-host.1.20261318.log")
+	notNil(err, t)
+
+	for _, tmpl := range []string{
+		"{prefix}{ext}",
+		"{time}{time}",
+		"{prefix}-{time",
+		"{prefix}-{date}",
+		"/var/log/{time}",
+		"../{time}",
+	} {
+		_, err := parseBackupTemplate(tmpl, "foo", ".log", backupTimeFormat, time.UTC)
+		notNil(err, t)
+	}
+}
+
+func TestBackupNameTemplate(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	dir := makeTempDir("TestBackupNameTemplate", t)
+	defer os.RemoveAll(dir)
+
+	_, err := NewRoller(logFile(dir), &Options{BackupNameTemplate: "{prefix}{ext}"})
+	notNil(err, t)
+
+	filename := logFile(dir)
+	r, err := NewRoller(filename, &Options{
+		MaxBackups:         2,
+		RotateType:         RotateDaily,
+		BackupNameTemplate: "{time:2006/01/02}/{prefix}{ext}",
+	})
+	isNil(err, t)
+	defer r.Close()
+
+	b := []byte("boo!")
+	for i := 0; i < 3; i++ {
+		n, err := r.Write(b)
+		isNil(err, t)
+		equals(len(b), n, t)
+		newFakeTime(24 * time.Hour)
+	}
+	b2 := []byte("foooooo!")
+	n, err := r.Write(b2)
+	isNil(err, t)
+	equals(len(b2), n, t)
+
+	// we need to wait a little bit since the files get deleted on a different
+	// goroutine.
+	<-time.After(10 * time.Millisecond)
+
+	notExist(filepath.Join(dir, "2026", "10", "18", "foobar.log"), t)
+	existsWithContent(filepath.Join(dir, "2026", "10", "19", "foobar.log"), b, t)
+	existsWithContent(filepath.Join(dir, "2026", "10", "20", "foobar.log"), b, t)
+	existsWithContent(filename, b2, t)
+
+	files, err := r.oldLogFiles()
+	isNil(err, t)
+	equals(2, len(files), t)
+	equals(filepath.Join(dir, "2026", "10", "20", "foobar.log"), files[0].path, t)
+}
@@ -0,0 +1,502 @@
+package lumberjack
+
+import (
+	"compress/bzip2"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// bzip2Suffix is the suffix of backups compressed by Bzip2Compressor.
+const bzip2Suffix = ".bz2"
+
+// Bzip2Compressor compresses backups with bzip2, which makes logs smaller than
+// gzip does, at the cost of being slower. Level is the block size in units of
+// 100 kB, from 1 to 9. The zero Level is 9.
+type Bzip2Compressor struct {
+	Level int
+}
+
+// Suffix implements Compressor.
+func (c Bzip2Compressor) Suffix() string {
+	return bzip2Suffix
+}
+
+// NewWriter implements Compressor.
+func (c Bzip2Compressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	level := c.Level
+	if level == 0 {
+		level = 9
+	}
+	if level < 1 || level > 9 {
+		return nil, fmt.Errorf("bzip2: invalid compression level: %d", level)
+	}
+	return &bzip2Writer{
+		bw:    bitWriter{w: w},
+		level: level,
+		// the run-length encoding of a block grows it by a quarter at
+		// most, and it must stay below the block size
+		max: (level*100000 - 19) * 4 / 5,
+	}, nil
+}
+
+// NewReader implements Compressor.
+func (c Bzip2Compressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bzip2.NewReader(r)), nil
+}
+
+const (
+	// bzip2MaxCodeLen is the longest Huffman code written. Decoders accept
+	// up to 20, bzip2 itself writes up to 17.
+	bzip2MaxCodeLen = 17
+	// bzip2GroupSize is how many symbols a Huffman table selector covers.
+	bzip2GroupSize = 50
+)
+
+// bzip2Writer compresses to bzip2, buffering a block of data at a time. Each
+// block is compressed with a single Huffman table, which is simpler than the
+// up to six bzip2 itself picks from, and costs a little compression.
+type bzip2Writer struct {
+	bw    bitWriter
+	level int
+	// block is the data of the block being collected, up to max bytes.
+	block []byte
+	max   int
+	// crc is the combined CRC of the blocks written.
+	crc     uint32
+	started bool
+	closed  bool
+}
+
+func (z *bzip2Writer) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, fmt.Errorf("bzip2: write to closed writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		n := z.max - len(z.block)
+		if n > len(p) {
+			n = len(p)
+		}
+		z.block = append(z.block, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(z.block) == z.max {
+			z.writeBlock()
+			if z.bw.err != nil {
+				return written, z.bw.err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close writes the last block and the end of the stream, but doesn't close
+// the underlying writer.
+func (z *bzip2Writer) Close() error {
+	if z.closed {
+		return z.bw.err
+	}
+	z.closed = true
+	if len(z.block) > 0 {
+		z.writeBlock()
+	}
+	z.writeHeader()
+	z.bw.bits(0x177245, 24)
+	z.bw.bits(0x385090, 24)
+	z.bw.bits(z.crc, 32)
+	return z.bw.flush()
+}
+
+// writeHeader writes the stream header, unless it has been.
+func (z *bzip2Writer) writeHeader() {
+	if z.started {
+		return
+	}
+	z.started = true
+	z.bw.bits('B', 8)
+	z.bw.bits('Z', 8)
+	z.bw.bits('h', 8)
+	z.bw.bits(uint32('0'+z.level), 8)
+}
+
+// writeBlock compresses and writes the block collected.
+func (z *bzip2Writer) writeBlock() {
+	z.writeHeader()
+	crc := bzip2CRC(z.block)
+	z.crc = (z.crc<<1 | z.crc>>31) ^ crc
+
+	data := bzip2RLE(z.block)
+	z.block = z.block[:0]
+	last, origPtr := bwt(data)
+
+	// the symbols are the move-to-front positions of the bytes used, with
+	// runs of the first position coded as RUNA and RUNB digits
+	var used [256]bool
+	for _, c := range data {
+		used[c] = true
+	}
+	var seq [256]byte
+	var mtf []byte
+	for c := range used {
+		if used[c] {
+			seq[c] = byte(len(mtf))
+			mtf = append(mtf, byte(len(mtf)))
+		}
+	}
+	const runA, runB = 0, 1
+	eob := uint16(len(mtf) + 1)
+	syms := make([]uint16, 0, len(last)+1)
+	run := 0
+	flushRun := func() {
+		if run == 0 {
+			return
+		}
+		for run--; ; run = (run - 2) / 2 {
+			if run&1 == 1 {
+				syms = append(syms, runB)
+			} else {
+				syms = append(syms, runA)
+			}
+			if run < 2 {
+				break
+			}
+		}
+		run = 0
+	}
+	for _, c := range last {
+		s := seq[c]
+		if mtf[0] == s {
+			run++
+			continue
+		}
+		flushRun()
+		j := 1
+		for mtf[j] != s {
+			j++
+		}
+		copy(mtf[1:j+1], mtf[:j])
+		mtf[0] = s
+		syms = append(syms, uint16(j+1))
+	}
+	flushRun()
+	syms = append(syms, eob)
+
+	freq := make([]int, eob+1)
+	for _, s := range syms {
+		freq[s]++
+	}
+	lengths := huffmanLengths(freq, bzip2MaxCodeLen)
+	codes := huffmanCodes(lengths)
+
+	bw := &z.bw
+	bw.bits(0x314159, 24)
+	bw.bits(0x265359, 24)
+	bw.bits(crc, 32)
+	// not randomized
+	bw.bits(0, 1)
+	bw.bits(uint32(origPtr), 24)
+	for i := 0; i < 16; i++ {
+		bw.bits(bool2bit(anyUsed(used[i*16:i*16+16])), 1)
+	}
+	for i := 0; i < 16; i++ {
+		if anyUsed(used[i*16 : i*16+16]) {
+			for _, u := range used[i*16 : i*16+16] {
+				bw.bits(bool2bit(u), 1)
+			}
+		}
+	}
+	// two tables, the fewest allowed, both the same and the first always
+	// selected
+	bw.bits(2, 3)
+	selectors := (len(syms) + bzip2GroupSize - 1) / bzip2GroupSize
+	bw.bits(uint32(selectors), 15)
+	for i := 0; i < selectors; i++ {
+		bw.bits(0, 1)
+	}
+	for t := 0; t < 2; t++ {
+		l := lengths[0]
+		bw.bits(uint32(l), 5)
+		for _, want := range lengths {
+			for ; l < want; l++ {
+				bw.bits(2, 2)
+			}
+			for ; l > want; l-- {
+				bw.bits(3, 2)
+			}
+			bw.bits(0, 1)
+		}
+	}
+	for _, s := range syms {
+		bw.bits(codes[s], uint(lengths[s]))
+	}
+}
+
+// bzip2RLE run-length encodes data the way bzip2 does before sorting it: runs
+// of 4 to 255 equal bytes become the first 4 followed by the count of the
+// rest.
+func bzip2RLE(data []byte) []byte {
+	out := make([]byte, 0, len(data)+len(data)/4)
+	for i := 0; i < len(data); {
+		c := data[i]
+		n := 1
+		for i+n < len(data) && n < 255 && data[i+n] == c {
+			n++
+		}
+		if n < 4 {
+			out = append(out, data[i:i+n]...)
+		} else {
+			out = append(out, c, c, c, c, byte(n-4))
+		}
+		i += n
+	}
+	return out
+}
+
+// bwt returns the Burrows-Wheeler transform of data, the last bytes of its
+// sorted rotations, and the position of data itself among them.
+func bwt(data []byte) ([]byte, int) {
+	n := len(data)
+	last := make([]byte, n)
+	origPtr := 0
+	for i, p := range sortRotations(data) {
+		if p == 0 {
+			origPtr = i
+			p = n
+		}
+		last[i] = data[p-1]
+	}
+	return last, origPtr
+}
+
+// sortRotations returns the starting positions of the rotations of data in
+// sorted order, sorting them by prefixes of doubling length with counting
+// sorts.
+func sortRotations(data []byte) []int {
+	n := len(data)
+	p := make([]int, n)
+	c := make([]int, n)
+	cnt := make([]int, 256)
+	if n > 256 {
+		cnt = make([]int, n)
+	}
+	for _, b := range data {
+		cnt[b]++
+	}
+	for i := 1; i < 256; i++ {
+		cnt[i] += cnt[i-1]
+	}
+	for i := n - 1; i >= 0; i-- {
+		cnt[data[i]]--
+		p[cnt[data[i]]] = i
+	}
+	classes := 1
+	for i := 1; i < n; i++ {
+		if data[p[i]] != data[p[i-1]] {
+			classes++
+		}
+		c[p[i]] = classes - 1
+	}
+
+	pn := make([]int, n)
+	cn := make([]int, n)
+	for h := 1; h < n && classes < n; h <<= 1 {
+		// p is sorted by the first h bytes, so shifting it back by h sorts
+		// by the second h bytes, and a stable sort by the first h finishes
+		for i, v := range p {
+			v -= h
+			if v < 0 {
+				v += n
+			}
+			pn[i] = v
+		}
+		for i := 0; i < classes; i++ {
+			cnt[i] = 0
+		}
+		for _, v := range pn {
+			cnt[c[v]]++
+		}
+		for i := 1; i < classes; i++ {
+			cnt[i] += cnt[i-1]
+		}
+		for i := n - 1; i >= 0; i-- {
+			cnt[c[pn[i]]]--
+			p[cnt[c[pn[i]]]] = pn[i]
+		}
+		cn[p[0]] = 0
+		classes = 1
+		for i := 1; i < n; i++ {
+			cur, prev := p[i], p[i-1]
+			if c[cur] != c[prev] || c[(cur+h)%n] != c[(prev+h)%n] {
+				classes++
+			}
+			cn[cur] = classes - 1
+		}
+		c, cn = cn, c
+	}
+	return p
+}
+
+// huffmanLengths returns the lengths of Huffman codes for symbols of the
+// given frequencies, at most maxLen long. Every symbol gets a code.
+func huffmanLengths(freq []int, maxLen int) []uint8 {
+	weights := make([]int, len(freq))
+	for i, f := range freq {
+		weights[i] = f
+		if f == 0 {
+			weights[i] = 1
+		}
+	}
+	for {
+		lengths, longest := huffmanDepths(weights)
+		if longest <= maxLen {
+			return lengths
+		}
+		// flatten the weights until the codes are short enough
+		for i := range weights {
+			weights[i] = 1 + weights[i]/2
+		}
+	}
+}
+
+// huffmanDepths returns the depths of the leaves of a Huffman tree for the
+// given weights, and the deepest.
+func huffmanDepths(weights []int) ([]uint8, int) {
+	n := len(weights)
+	weight := make([]int, n, 2*n)
+	copy(weight, weights)
+	parent := make([]int, 2*n)
+	active := make([]int, n)
+	for i := range active {
+		active[i] = i
+	}
+	for len(active) > 1 {
+		// take out the two lightest nodes and join them
+		var pair [2]int
+		for k := range pair {
+			min := 0
+			for i := range active {
+				if weight[active[i]] < weight[active[min]] {
+					min = i
+				}
+			}
+			pair[k] = active[min]
+			active[min] = active[len(active)-1]
+			active = active[:len(active)-1]
+		}
+		node := len(weight)
+		weight = append(weight, weight[pair[0]]+weight[pair[1]])
+		parent[pair[0]], parent[pair[1]] = node, node
+		active = append(active, node)
+	}
+	root := len(weight) - 1
+	lengths := make([]uint8, n)
+	longest := 0
+	for i := range lengths {
+		depth := 0
+		for v := i; v != root; v = parent[v] {
+			depth++
+		}
+		lengths[i] = uint8(depth)
+		if depth > longest {
+			longest = depth
+		}
+	}
+	return lengths, longest
+}
+
+// huffmanCodes returns the canonical Huffman codes of the given lengths, in
+// the order bzip2 assigns them: by length, then by symbol.
+func huffmanCodes(lengths []uint8) []uint32 {
+	codes := make([]uint32, len(lengths))
+	code := uint32(0)
+	for l := uint8(1); l <= 32; l++ {
+		for s, sl := range lengths {
+			if sl == l {
+				codes[s] = code
+				code++
+			}
+		}
+		code <<= 1
+	}
+	return codes
+}
+
+// bzip2CRC returns the CRC of data as bzip2 computes it, a big-endian CRC-32.
+func bzip2CRC(data []byte) uint32 {
+	crc := ^uint32(0)
+	for _, b := range data {
+		crc = crc<<8 ^ bzip2CRCTable[byte(crc>>24)^b]
+	}
+	return ^crc
+}
+
+var bzip2CRCTable = func() (table [256]uint32) {
+	for i := range table {
+		c := uint32(i) << 24
+		for k := 0; k < 8; k++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04c11db7
+			} else {
+				c <<= 1
+			}
+		}
+		table[i] = c
+	}
+	return table
+}()
+
+func anyUsed(used []bool) bool {
+	for _, u := range used {
+		if u {
+			return true
+		}
+	}
+	return false
+}
+
+func bool2bit(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// bitWriter writes bits, most significant first.
+type bitWriter struct {
+	w   io.Writer
+	out []byte
+	// acc holds the n bits not yet in out.
+	acc uint64
+	n   uint
+	err error
+}
+
+// bits writes the low n bits of v, n being at most 32.
+func (b *bitWriter) bits(v uint32, n uint) {
+	b.acc = b.acc<<n | uint64(v)&(1<<n-1)
+	b.n += n
+	for b.n >= 8 {
+		b.n -= 8
+		b.out = append(b.out, byte(b.acc>>b.n))
+	}
+	if len(b.out) >= 64*1024 {
+		b.write()
+	}
+}
+
+// flush writes out the bits written, padding the last byte with zeros.
+func (b *bitWriter) flush() error {
+	if b.n > 0 {
+		b.bits(0, 8-b.n)
+	}
+	b.write()
+	return b.err
+}
+
+func (b *bitWriter) write() {
+	if b.err == nil && len(b.out) > 0 {
+		_, b.err = b.w.Write(b.out)
+	}
+	b.out = b.out[:0]
+}
@@ -0,0 +1,97 @@
+package lumberjack
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBzip2Compressor(t *testing.T) {
+	var logs bytes.Buffer
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&logs, "2026-10-18T10:%02d:%02d level=info msg=\"request served\" status=%d bytes=%d\n",
+			i/60%60, i%60, 200+i%3, i*7%1000)
+	}
+	random := make([]byte, 300000)
+	rand.New(rand.NewSource(1)).Read(random)
+	tests := [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte("boo!"),
+		bytes.Repeat([]byte("a"), 1000),
+		bytes.Repeat([]byte("ab"), 1000),
+		bytes.Repeat([]byte("aaaab"), 300),
+		random,
+		logs.Bytes(),
+	}
+	for i, data := range tests {
+		for _, level := range []int{1, 0} {
+			var buf bytes.Buffer
+			w, err := Bzip2Compressor{Level: level}.NewWriter(&buf)
+			isNil(err, t)
+			_, err = w.Write(data)
+			isNil(err, t)
+			isNil(w.Close(), t)
+
+			r, err := Bzip2Compressor{}.NewReader(&buf)
+			isNil(err, t)
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("test %d level %d: %v", i, level, err)
+			}
+			equals(true, bytes.Equal(data, got), t)
+		}
+	}
+
+	// logs compress better than with gzip
+	var bz, gz bytes.Buffer
+	w, err := Bzip2Compressor{}.NewWriter(&bz)
+	isNil(err, t)
+	_, err = w.Write(logs.Bytes())
+	isNil(err, t)
+	isNil(w.Close(), t)
+	gw, err := gzip.NewWriterLevel(&gz, gzip.BestCompression)
+	isNil(err, t)
+	_, err = gw.Write(logs.Bytes())
+	isNil(err, t)
+	isNil(gw.Close(), t)
+	equals(true, bz.Len() < gz.Len(), t)
+
+	_, err = Bzip2Compressor{Level: 10}.NewWriter(ioutil.Discard)
+	notNil(err, t)
+}
+
+func TestBzip2Roller(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestBzip2Roller", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{Compress: true, Compressor: Bzip2Compressor{}})
+	isNil(err, t)
+	defer l.Close()
+
+	_, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+
+	// we need to wait a little bit since the files get compressed on a
+	// different goroutine.
+	<-time.After(300 * time.Millisecond)
+
+	f, err := os.Open(backupFile(dir) + ".bz2")
+	isNil(err, t)
+	defer f.Close()
+	zr, err := compressorFor(f.Name()).NewReader(f)
+	isNil(err, t)
+	b, err := ioutil.ReadAll(zr)
+	isNil(err, t)
+	equals("boo!", string(b), t)
+	fileCount(dir, 2, t)
+}
@@ -17,6 +17,11 @@ func chown(name string, info os.FileInfo) error {
 		return err
 	}
 	f.Close()
+	return chownFile(name, info)
+}
+
+// chownFile gives the existing file name the owner of info.
+func chownFile(name string, info os.FileInfo) error {
 	stat := info.Sys().(*syscall.Stat_t)
 	return osChown(name, int(stat.Uid), int(stat.Gid))
 }
@@ -0,0 +1,117 @@
+package lumberjack
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Compressor compresses backups. Besides the built-in GzipCompressor and
+// Bzip2Compressor, other codecs such as zstd can be plugged in by implementing
+// it, e.g. on top of github.com/klauspost/compress/zstd.
+type Compressor interface {
+	// Suffix is appended to the name of compressed backups, e.g. ".gz".
+	Suffix() string
+	// NewWriter returns a writer compressing to w. Closing it flushes the
+	// compressed data, but doesn't close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader decompressing r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// GzipCompressor compresses backups with gzip, at the given compression level
+// from gzip.BestSpeed to gzip.BestCompression. The zero Level is
+// gzip.DefaultCompression.
+type GzipCompressor struct {
+	Level int
+}
+
+// Suffix implements Compressor.
+func (c GzipCompressor) Suffix() string {
+	return compressSuffix
+}
+
+// NewWriter implements Compressor.
+func (c GzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+// NewReader implements Compressor.
+func (c GzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	// compressors are the registered compressors by suffix.
+	compressors = map[string]Compressor{
+		compressSuffix: GzipCompressor{},
+		bzip2Suffix:    Bzip2Compressor{},
+	}
+)
+
+// RegisterCompressor registers a compressor, so that backups compressed with
+// it are recognized by every Roller, whichever compressor it uses itself. The
+// compressor given in Options is registered by NewRoller.
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Suffix()] = c
+}
+
+// compressorFor returns the registered compressor name was compressed with,
+// or nil if it isn't compressed.
+func compressorFor(name string) Compressor {
+	suffix := compressedSuffix(name)
+	if suffix == "" {
+		return nil
+	}
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	return compressors[suffix]
+}
+
+// compressSuffixes returns the suffixes of the registered compressors,
+// longest first.
+func compressSuffixes() []string {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	suffixes := make([]string, 0, len(compressors))
+	for suffix := range compressors {
+		suffixes = append(suffixes, suffix)
+	}
+	sort.Slice(suffixes, func(i, j int) bool {
+		if len(suffixes[i]) != len(suffixes[j]) {
+			return len(suffixes[i]) > len(suffixes[j])
+		}
+		return suffixes[i] < suffixes[j]
+	})
+	return suffixes
+}
+
+// compressedSuffix returns the suffix of the compressor name was compressed
+// with, or "" if it isn't compressed.
+func compressedSuffix(name string) string {
+	for _, suffix := range compressSuffixes() {
+		if strings.HasSuffix(name, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// isCompressed reports whether name is compressed by a registered compressor.
+func isCompressed(name string) bool {
+	return compressedSuffix(name) != ""
+}
+
+// trimCompressed returns name without the suffix of its compressor.
+func trimCompressed(name string) string {
+	return strings.TrimSuffix(name, compressedSuffix(name))
+}
@@ -0,0 +1,176 @@
+package lumberjack
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// zlibCompressor stands in for a codec plugged in by the user.
+type zlibCompressor struct{}
+
+func (zlibCompressor) Suffix() string { return ".zz" }
+
+func (zlibCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+
+func (zlibCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+func TestCompressor(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestCompressor", t)
+	defer os.RemoveAll(dir)
+
+	// a backup compressed with gzip before switching codecs
+	old := backupFile(dir)
+	isNil(ioutil.WriteFile(old+compressSuffix, gzipped([]byte("old"), t), 0644), t)
+
+	newFakeTime()
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{Compress: true, Compressor: zlibCompressor{}, MaxBackups: 2, MaxSize: 10})
+	isNil(err, t)
+	defer l.Close()
+
+	for _, s := range []string{"boo!", "foooooo!"} {
+		_, err = l.Write([]byte(s))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+
+		// we need to wait a little bit since the files get compressed on a
+		// different goroutine.
+		<-time.After(300 * time.Millisecond)
+	}
+
+	// the gzip backup counts towards MaxBackups
+	notExist(old+compressSuffix, t)
+	fileCount(dir, 3, t)
+
+	f, err := os.Open(backupFile(dir) + ".zz")
+	isNil(err, t)
+	defer f.Close()
+	zr, err := compressorFor(f.Name()).NewReader(f)
+	isNil(err, t)
+	b, err := ioutil.ReadAll(zr)
+	isNil(err, t)
+	equals("foooooo!", string(b), t)
+}
+
+func TestGzipCompressorLevel(t *testing.T) {
+	data := bytes.Repeat([]byte("boo! foo! bar! "), 1000)
+	size := func(level int) int {
+		var buf bytes.Buffer
+		w, err := GzipCompressor{Level: level}.NewWriter(&buf)
+		isNil(err, t)
+		_, err = w.Write(data)
+		isNil(err, t)
+		isNil(w.Close(), t)
+		return buf.Len()
+	}
+	equals(true, size(gzip.HuffmanOnly) > size(gzip.BestCompression), t)
+
+	_, err := GzipCompressor{Level: 42}.NewWriter(ioutil.Discard)
+	notNil(err, t)
+	_, err = NewRoller(logFile(os.TempDir()), &Options{Compress: true, CompressLevel: 42})
+	notNil(err, t)
+
+	equals(".gz", compressedSuffix("foo.log.gz"), t)
+	equals("", compressedSuffix("foo.log"), t)
+	equals("foo.log", trimCompressed("foo.log.gz"), t)
+}
+
+func TestCompressAfter(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	dir := makeTempDir("TestCompressAfter", t)
+	defer os.RemoveAll(dir)
+
+	// four backups, one a day
+	var names []string
+	for d := 1; d <= 4; d++ {
+		ts := fakeCurrentTime.Add(-time.Duration(d) * 24 * time.Hour)
+		name := filepath.Join(dir, fmt.Sprintf("foobar-%s.log", ts.Format(backupTimeFormat)))
+		isNil(ioutil.WriteFile(name, []byte("boo!"), 0644), t)
+		names = append(names, name)
+	}
+
+	l := &Roller{filename: logFile(dir), compress: true, compressor: GzipCompressor{}, compressAfter: 1}
+	steps, err := l.PlanMaintenance()
+	isNil(err, t)
+	equals(3, len(steps), t)
+	equals(names[1], steps[0].Path, t)
+
+	l.compressAfterAge = 60 * time.Hour
+	steps, err = l.PlanMaintenance()
+	isNil(err, t)
+	equals(2, len(steps), t)
+	equals(names[2], steps[0].Path, t)
+
+	_, err = l.RunMaintenance(context.Background())
+	isNil(err, t)
+	exists(names[0], t)
+	exists(names[1], t)
+	exists(names[2]+compressSuffix, t)
+	exists(names[3]+compressSuffix, t)
+}
+
+func TestCompressWorkers(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestCompressWorkers", t)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 6; i++ {
+		newFakeTime()
+		isNil(ioutil.WriteFile(backupFile(dir), []byte("boo!"), 0644), t)
+	}
+
+	l := &Roller{filename: logFile(dir), compress: true, compressor: GzipCompressor{}, compressWorkers: 3, compressNice: 10}
+	results, err := l.RunMaintenance(context.Background())
+	isNil(err, t)
+	equals(6, len(results), t)
+	for _, res := range results {
+		isNil(res.Err, t)
+		existsWithContent(res.Path+compressSuffix, gzipped([]byte("boo!"), t), t)
+		notExist(res.Path, t)
+	}
+}
+
+func TestCompressRateLimit(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestCompressRateLimit", t)
+	defer os.RemoveAll(dir)
+
+	backup := backupFile(dir)
+	isNil(ioutil.WriteFile(backup, make([]byte, 64*1024), 0644), t)
+
+	l := &Roller{filename: logFile(dir), compress: true, compressor: GzipCompressor{}, throttle: newThrottle(128 * 1024)}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// cut short, the backup is left as it was
+	results, err := l.RunMaintenance(ctx)
+	isNil(err, t)
+	equals(1, len(results), t)
+	equals(context.DeadlineExceeded, errors.Unwrap(results[0].Err), t)
+	exists(backup, t)
+	notExist(backup+compressSuffix, t)
+
+	start := time.Now()
+	results, err = l.RunMaintenance(context.Background())
+	isNil(err, t)
+	isNil(results[0].Err, t)
+	equals(true, time.Since(start) >= 400*time.Millisecond, t)
+	exists(backup+compressSuffix, t)
+}
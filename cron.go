@@ -0,0 +1,240 @@
+package lumberjack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed standard 5-field cron expression:
+//
+//	minute hour day-of-month month day-of-week
+//
+// Each field is a bit set of the values it matches.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// domStar and dowStar record whether the day fields were "*". As in
+	// cron(8), if both are restricted a day matches if either field does.
+	domStar, dowStar bool
+}
+
+// cronField describes the range and value names of a cron field.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	cronMinute = cronField{"minute", 0, 59, nil}
+	cronHour   = cronField{"hour", 0, 23, nil}
+	cronDom    = cronField{"day of month", 1, 31, nil}
+	cronMonth  = cronField{"month", 1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	// day of week 7 is Sunday too
+	cronDow = cronField{"day of week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses a 5-field cron expression. Fields may be "*", values,
+// ranges "a-b", steps "*/n" or "a-b/n" and comma separated lists of those.
+// Months and days of week may be given by their three letter English names.
+// The macros @yearly, @monthly, @weekly, @daily and @hourly are supported.
+func parseCron(spec string) (*cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields", spec)
+	}
+	c := &cronSchedule{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	var err error
+	if c.minute, err = cronMinute.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if c.hour, err = cronHour.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if c.dom, err = cronDom.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if c.month, err = cronMonth.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if c.dow, err = cronDow.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 << 0
+	}
+	return c, nil
+}
+
+// parse returns the bit set of values matched by the field.
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		lo, hi, step := f.min, f.max, 1
+		rng := part
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in cron %s field %q", f.name, part)
+			}
+			rng, step = part[:i], n
+		}
+		if rng != "*" {
+			var err error
+			if i := strings.Index(rng, "-"); i >= 0 {
+				if lo, err = f.value(rng[:i]); err != nil {
+					return 0, err
+				}
+				if hi, err = f.value(rng[i+1:]); err != nil {
+					return 0, err
+				}
+			} else {
+				if lo, err = f.value(rng); err != nil {
+					return 0, err
+				}
+				hi = lo
+				// "a/n" means from a to the end of the range
+				if step > 1 {
+					hi = f.max
+				}
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range in cron %s field %q", f.name, part)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single value of the field, either a number or a name.
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q in cron %s field", s, f.name)
+	}
+	return v, nil
+}
+
+// matchDay reports whether the schedule fires on the given day.
+func (c *cronSchedule) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first time after t the schedule fires, in t's location,
+// or the zero time if it doesn't fire within the next five years.
+//
+// The schedule is matched against wall clock time. A time skipped by a
+// daylight saving transition fires right after the transition, and a time
+// repeated by one fires only once.
+func (c *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	y, mo, d := t.Date()
+	h, mi := t.Hour(), t.Minute()+1
+	end := y + 5
+	for {
+		// normalize the wall clock fields after a carry
+		wall := time.Date(y, mo, d, h, mi, 0, 0, time.UTC)
+		y, mo, d = wall.Date()
+		h, mi = wall.Hour(), wall.Minute()
+		if y > end {
+			return time.Time{}
+		}
+		switch {
+		case c.month&(1<<uint(mo)) == 0:
+			mo, d, h, mi = mo+1, 1, 0, 0
+		case !c.matchDay(wall):
+			d, h, mi = d+1, 0, 0
+		case c.hour&(1<<uint(h)) == 0:
+			h, mi = h+1, 0
+		case c.minute&(1<<uint(mi)) == 0:
+			mi++
+		default:
+			next := time.Date(y, mo, d, h, mi, 0, 0, loc)
+			// the wall clock time was skipped, move on to the transition
+			for wallClock(next).Before(wall) {
+				next = next.Add(time.Minute)
+			}
+			if next.After(t) {
+				return next
+			}
+			// the wall clock time was repeated, and its first occurrence
+			// has passed
+			mi++
+		}
+	}
+}
+
+// wallClock returns the wall clock time of t as a time in UTC.
+func wallClock(t time.Time) time.Time {
+	y, mo, d := t.Date()
+	return time.Date(y, mo, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// CronPolicy rotates the log file on a cron schedule.
+type CronPolicy struct {
+	schedule  *cronSchedule
+	localTime bool
+}
+
+// NewCronPolicy returns a policy rotating on the schedule of the 5-field cron
+// expression spec, evaluated in local time if localTime is true and in UTC
+// otherwise.
+func NewCronPolicy(spec string, localTime bool) (*CronPolicy, error) {
+	schedule, err := parseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &CronPolicy{schedule: schedule, localTime: localTime}, nil
+}
+
+// ShouldRotate implements RotationPolicy.
+func (p *CronPolicy) ShouldRotate(s RotationState) bool {
+	deadline := p.NextDeadline(s.Opened)
+	return !deadline.IsZero() && !s.Now.Before(deadline)
+}
+
+// NextDeadline implements RotationPolicy.
+func (p *CronPolicy) NextDeadline(now time.Time) time.Time {
+	if p.localTime {
+		return p.schedule.next(now.In(time.Local))
+	}
+	return p.schedule.next(now.UTC())
+}
+
+// BackupTimestamp implements RotationPolicy, stamping backups with the time
+// the log file was opened.
+func (p *CronPolicy) BackupTimestamp(s RotationState) time.Time {
+	return s.Opened
+}
@@ -0,0 +1,149 @@
+package lumberjack
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"30 2,14 * * *", false},
+		{"0 0 * * mon-fri", false},
+		{"*/15 9-17/2 1,15 jan-jun,DEC 7", false},
+		{"5/10 * * * *", false},
+		{"@daily", false},
+		{"", true},
+		{"* * * *", true},
+		{"* * * * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"5-1 * * * *", true},
+		{"*/0 * * * *", true},
+		{"* * * foo *", true},
+	}
+	for _, test := range tests {
+		_, err := parseCron(test.spec)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseCron(%q): unexpected error %v", test.spec, err)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		spec string
+		now  time.Time
+		want time.Time
+	}{
+		{"30 2,14 * * *", time.Date(2026, 10, 18, 2, 29, 59, 0, time.UTC), time.Date(2026, 10, 18, 2, 30, 0, 0, time.UTC)},
+		{"30 2,14 * * *", time.Date(2026, 10, 18, 2, 30, 0, 0, time.UTC), time.Date(2026, 10, 18, 14, 30, 0, 0, time.UTC)},
+		{"30 2,14 * * *", time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 2, 30, 0, 0, time.UTC)},
+		// 2026-10-17 is a Saturday
+		{"0 0 * * mon-fri", time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1-5", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)},
+		// either the day of month or the day of week matches
+		{"0 0 1 * sun", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * sun", time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 5, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 12, 5, 0, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}},
+	}
+	for _, test := range tests {
+		c, err := parseCron(test.spec)
+		isNil(err, t)
+		got := c.next(test.now)
+		if !got.Equal(test.want) {
+			t.Errorf("%q after %v: got %v, want %v", test.spec, test.now, got, test.want)
+		}
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+	edt := time.FixedZone("EDT", -4*60*60)
+	est := time.FixedZone("EST", -5*60*60)
+
+	tests := []struct {
+		spec string
+		now  time.Time
+		want time.Time
+	}{
+		// 02:30 doesn't exist on 2026-03-08, fire when the clock jumps to 03:00
+		{"30 2 * * *", time.Date(2026, 3, 8, 0, 0, 0, 0, loc), time.Date(2026, 3, 8, 3, 0, 0, 0, edt)},
+		{"30 3 * * *", time.Date(2026, 3, 8, 0, 0, 0, 0, loc), time.Date(2026, 3, 8, 3, 30, 0, 0, edt)},
+		{"0 0 * * *", time.Date(2026, 3, 8, 0, 0, 0, 0, loc), time.Date(2026, 3, 9, 0, 0, 0, 0, loc)},
+		// 01:30 happens twice on 2026-11-01, fire on the first one only
+		{"30 1 * * *", time.Date(2026, 11, 1, 0, 0, 0, 0, loc), time.Date(2026, 11, 1, 1, 30, 0, 0, edt)},
+		{"30 1 * * *", time.Date(2026, 11, 1, 1, 30, 0, 0, edt), time.Date(2026, 11, 2, 1, 30, 0, 0, est)},
+		{"0 * * * *", time.Date(2026, 11, 1, 1, 0, 0, 0, edt), time.Date(2026, 11, 1, 2, 0, 0, 0, est)},
+	}
+	for _, test := range tests {
+		c, err := parseCron(test.spec)
+		isNil(err, t)
+		got := c.next(test.now.In(loc))
+		if !got.Equal(test.want) {
+			t.Errorf("%q after %v: got %v, want %v", test.spec, test.now, got, test.want)
+		}
+	}
+}
+
+func TestTimeRotateCron(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)
+	dir := makeTempDir("TestTimeRotateCron", t)
+	defer os.RemoveAll(dir)
+
+	_, err := NewRoller(logFile(dir), &Options{RotateType: RotateCron, RotateCron: "30 2 * *"})
+	notNil(err, t)
+	// cron has no fixed period to count MaxAge in
+	_, err = NewRoller(logFile(dir), &Options{RotateType: RotateCron, RotateCron: "@daily", MaxAge: 7})
+	notNil(err, t)
+
+	filename := logFile(dir)
+	r, err := NewRoller(filename, &Options{RotateType: RotateCron, RotateCron: "30 2,14 * * *"})
+	isNil(err, t)
+	defer r.Close()
+
+	b := []byte("boo!")
+	n, err := r.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+
+	fakeCurrentTime = time.Date(2026, 10, 18, 2, 29, 0, 0, time.UTC)
+	n, err = r.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	fileCount(dir, 1, t)
+
+	fakeCurrentTime = time.Date(2026, 10, 18, 2, 30, 0, 0, time.UTC)
+	b2 := []byte("foooooo!")
+	n, err = r.Write(b2)
+	isNil(err, t)
+	equals(len(b2), n, t)
+	existsWithContent(filename, b2, t)
+	fileCount(dir, 2, t)
+
+	fakeCurrentTime = time.Date(2026, 10, 18, 14, 0, 0, 0, time.UTC)
+	n, err = r.Write(b2)
+	isNil(err, t)
+	equals(len(b2), n, t)
+	fileCount(dir, 2, t)
+
+	fakeCurrentTime = time.Date(2026, 10, 18, 14, 30, 0, 0, time.UTC)
+	n, err = r.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	existsWithContent(filename, b, t)
+	fileCount(dir, 3, t)
+}
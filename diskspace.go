@@ -0,0 +1,160 @@
+package lumberjack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DiskFullMode is what a Roller does with writes while the free space of the
+// filesystem of the log file or of its backups is below
+// Options.CriticalFreeSpace.
+type DiskFullMode string
+
+var (
+	// DiskFullDrop drops the writes, which return ErrDiskFull.
+	DiskFullDrop DiskFullMode = "drop"
+	// DiskFullBlock blocks the writes until enough space is freed. Space
+	// on the log file's filesystem has to be freed by someone else if the
+	// backups are on another one.
+	DiskFullBlock DiskFullMode = "block"
+	// DiskFullFallback writes to Options.FallbackPath instead.
+	DiskFullFallback DiskFullMode = "fallback"
+)
+
+func IsLegalDiskFullMode(m DiskFullMode) bool {
+	return m == "" || m == DiskFullDrop || m == DiskFullBlock || m == DiskFullFallback
+}
+
+// ErrDiskFull is returned by writes dropped because the free space of the
+// filesystem of the log file or of its backups is below
+// Options.CriticalFreeSpace.
+const ErrDiskFull = constError("free disk space below critical threshold")
+
+// diskState is how much free space the filesystems of the log file and its
+// backups have left.
+type diskState int
+
+const (
+	diskOK diskState = iota
+	// diskLow is below Options.MinFreeSpace, backups are pruned.
+	diskLow
+	// diskCritical is below Options.CriticalFreeSpace, writes follow
+	// Options.DiskFullMode.
+	diskCritical
+)
+
+var (
+	// diskFree exists so it can be mocked out by tests.
+	diskFree = freeSpace
+
+	// diskCheckInterval is how long the free space is cached for, so that
+	// not every write has to ask the filesystem.
+	diskCheckInterval = time.Second
+
+	// diskPollInterval is how often blocked writes check the free space.
+	diskPollInterval = time.Second
+)
+
+// guardDisk reports whether the free space guard is enabled.
+func (r *Roller) guardDisk() bool {
+	return r.minFreeSpace > 0 || r.criticalFreeSpace > 0
+}
+
+// minFree returns the free space of the filesystem of the log file or of its
+// backups, whichever has less. Either is enough if the other can't be asked.
+func (r *Roller) minFree() (int64, error) {
+	free, err := diskFree(r.dir())
+	if dir := r.backupDir(); dir != r.dir() {
+		if bfree, berr := diskFree(dir); berr == nil && (err != nil || bfree < free) {
+			free, err = bfree, nil
+		}
+	}
+	return free, err
+}
+
+// checkDisk returns the state of the filesystems of the log file and its
+// backups, asking them at most once every diskCheckInterval. Entering a low
+// state starts pruning backups and is reported to the hook. r.mu must be
+// held.
+func (r *Roller) checkDisk() diskState {
+	now := currentTime()
+	if !r.diskCheckedAt.IsZero() && now.Sub(r.diskCheckedAt) < diskCheckInterval {
+		return r.disk
+	}
+	r.diskCheckedAt = now
+	free, err := r.minFree()
+	if err != nil {
+		// unknown, keep going as before
+		return r.disk
+	}
+	state := diskOK
+	switch {
+	case r.criticalFreeSpace > 0 && free < r.criticalFreeSpace:
+		state = diskCritical
+	case r.minFreeSpace > 0 && free < r.minFreeSpace:
+		state = diskLow
+	}
+	if state == r.disk {
+		return state
+	}
+	if state > r.disk {
+		r.mill()
+		if r.Hook != nil && r.Hook.DiskSpaceLow != nil {
+			go r.Hook.DiskSpaceLow(free, state == diskCritical)
+		}
+	}
+	if state != diskCritical {
+		// back to the log file
+		_ = r.closeFallback()
+	}
+	r.disk = state
+	return state
+}
+
+// writeDiskFull handles a write while the free space is critical. done is
+// false if the write should go on to the log file after all. r.mu must be
+// held.
+func (r *Roller) writeDiskFull(p []byte) (n int, done bool, err error) {
+	switch r.diskFullMode {
+	case DiskFullBlock:
+		for r.disk == diskCritical {
+			r.mu.Unlock()
+			time.Sleep(diskPollInterval)
+			r.mu.Lock()
+			r.diskCheckedAt = time.Time{}
+			r.checkDisk()
+		}
+		return 0, false, nil
+	case DiskFullFallback:
+		n, err = r.writeFallback(p)
+		return n, true, err
+	}
+	return 0, true, ErrDiskFull
+}
+
+// writeFallback writes p to the fallback file, opening it if necessary.
+func (r *Roller) writeFallback(p []byte) (int, error) {
+	if r.fallback == nil {
+		if err := os.MkdirAll(filepath.Dir(r.fallbackPath), 0755); err != nil {
+			return 0, fmt.Errorf("can't make directories for fallback file: %w", err)
+		}
+		f, err := os.OpenFile(r.fallbackPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return 0, fmt.Errorf("can't open fallback file: %w", err)
+		}
+		r.fallback = f
+	}
+	return r.fallback.Write(p)
+}
+
+// closeFallback closes the fallback file if it is open.
+func (r *Roller) closeFallback() error {
+	if r.fallback == nil {
+		return nil
+	}
+	err := r.fallback.Close()
+	r.fallback = nil
+	return err
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package lumberjack
+
+import "errors"
+
+// freeSpace is only supported on linux, elsewhere the free space guard is
+// disabled.
+func freeSpace(_ string) (int64, error) {
+	return 0, errors.New("free space is not supported on this platform")
+}
@@ -0,0 +1,214 @@
+package lumberjack
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeDisk mocks the free space of the filesystem, returning a function
+// restoring it.
+func fakeDisk(free func() int64) func() {
+	diskFree = func(string) (int64, error) {
+		return free(), nil
+	}
+	diskCheckInterval = 0
+	diskPollInterval = time.Millisecond
+	return func() {
+		diskFree = freeSpace
+		diskCheckInterval = time.Second
+		diskPollInterval = time.Second
+	}
+}
+
+func TestDiskSpaceLowPrunes(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestDiskSpaceLowPrunes", t)
+	defer os.RemoveAll(dir)
+
+	// deleting each backup frees 50 bytes
+	base := int64(1000)
+	defer fakeDisk(func() int64 {
+		files, _ := filepath.Glob(filepath.Join(dir, "foobar-*"))
+		return atomic.LoadInt64(&base) - 50*int64(len(files))
+	})()
+
+	reported := make(chan bool, 1)
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{
+		MaxSize:      10,
+		MinFreeSpace: 100,
+		Hook:         &Hook{DiskSpaceLow: func(_ int64, critical bool) { reported <- critical }},
+	})
+	isNil(err, t)
+	defer l.Close()
+
+	first := backupFile(dir)
+	for _, b := range []string{"boo!", "foooooo!"} {
+		_, err = l.Write([]byte(b))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+	}
+	<-time.After(10 * time.Millisecond)
+	fileCount(dir, 3, t)
+
+	// 90 bytes are free, the oldest backup has to go to get 100
+	atomic.StoreInt64(&base, 190)
+	_, err = l.Write([]byte("baaar!"))
+	isNil(err, t)
+	select {
+	case critical := <-reported:
+		equals(false, critical, t)
+	case <-time.After(time.Second):
+		t.Fatal("low disk space wasn't reported")
+	}
+
+	// we need to wait a little bit since the files get deleted on a different
+	// goroutine.
+	<-time.After(10 * time.Millisecond)
+
+	notExist(first, t)
+	existsWithContent(backupFile(dir), []byte("foooooo!"), t)
+	fileCount(dir, 2, t)
+}
+
+func TestDiskFullDrop(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestDiskFullDrop", t)
+	defer os.RemoveAll(dir)
+
+	free := int64(10)
+	defer fakeDisk(func() int64 { return atomic.LoadInt64(&free) })()
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{CriticalFreeSpace: 50})
+	isNil(err, t)
+	defer l.Close()
+
+	n, err := l.Write([]byte("boo!"))
+	equals(0, n, t)
+	equals(true, errors.Is(err, ErrDiskFull), t)
+	existsWithContent(filename, []byte{}, t)
+
+	atomic.StoreInt64(&free, 1000)
+	n, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+	equals(4, n, t)
+	existsWithContent(filename, []byte("boo!"), t)
+}
+
+func TestDiskFullFallback(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestDiskFullFallback", t)
+	defer os.RemoveAll(dir)
+
+	free := int64(10)
+	defer fakeDisk(func() int64 { return atomic.LoadInt64(&free) })()
+
+	filename := logFile(dir)
+	fallback := filepath.Join(dir, "fallback", "foobar.log")
+	_, err := NewRoller(filename, &Options{CriticalFreeSpace: 50, DiskFullMode: DiskFullFallback})
+	notNil(err, t)
+
+	l, err := NewRoller(filename, &Options{CriticalFreeSpace: 50, DiskFullMode: DiskFullFallback, FallbackPath: fallback})
+	isNil(err, t)
+	defer l.Close()
+
+	_, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+	existsWithContent(fallback, []byte("boo!"), t)
+	existsWithContent(filename, []byte{}, t)
+
+	atomic.StoreInt64(&free, 1000)
+	_, err = l.Write([]byte("foo!"))
+	isNil(err, t)
+	existsWithContent(fallback, []byte("boo!"), t)
+	existsWithContent(filename, []byte("foo!"), t)
+}
+
+func TestDiskFullBlock(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestDiskFullBlock", t)
+	defer os.RemoveAll(dir)
+
+	free := int64(10)
+	defer fakeDisk(func() int64 { return atomic.LoadInt64(&free) })()
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{CriticalFreeSpace: 50, DiskFullMode: DiskFullBlock})
+	isNil(err, t)
+	defer l.Close()
+
+	done := make(chan error)
+	go func() {
+		_, err := l.Write([]byte("boo!"))
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("write didn't block while the disk is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	atomic.StoreInt64(&free, 1000)
+	select {
+	case err := <-done:
+		isNil(err, t)
+	case <-time.After(time.Second):
+		t.Fatal("write still blocked after space was freed")
+	}
+	existsWithContent(filename, []byte("boo!"), t)
+}
+
+func TestDiskSpaceLowArchiveDir(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestDiskSpaceLowArchiveDir", t)
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "archive")
+
+	// the log file's filesystem has plenty, deleting each backup frees 50
+	// bytes on the backups' one
+	base := int64(1000)
+	defer fakeDisk(func() int64 { return 0 })()
+	diskFree = func(path string) (int64, error) {
+		if path != archive {
+			return 1000, nil
+		}
+		files, _ := filepath.Glob(filepath.Join(archive, "foobar-*"))
+		return atomic.LoadInt64(&base) - 50*int64(len(files)), nil
+	}
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{MaxSize: 10, MinFreeSpace: 100, ArchiveDir: "archive"})
+	isNil(err, t)
+	defer l.Close()
+
+	first := backupFile(archive)
+	for _, b := range []string{"boo!", "foooooo!"} {
+		_, err = l.Write([]byte(b))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+	}
+	<-time.After(10 * time.Millisecond)
+	fileCount(archive, 2, t)
+
+	// 90 bytes are free on the backups' filesystem, the oldest backup has to
+	// go to get 100
+	atomic.StoreInt64(&base, 190)
+	_, err = l.Write([]byte("baaar!"))
+	isNil(err, t)
+
+	// we need to wait a little bit since the files get deleted on a different
+	// goroutine.
+	<-time.After(10 * time.Millisecond)
+
+	notExist(first, t)
+	existsWithContent(backupFile(archive), []byte("foooooo!"), t)
+	fileCount(archive, 1, t)
+}
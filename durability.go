@@ -0,0 +1,103 @@
+package lumberjack
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	// defaultSyncBytes is how many bytes DurabilityBytes writes between syncs.
+	defaultSyncBytes = 1024 * 1024
+	// defaultSyncInterval is how often DurabilityInterval syncs.
+	defaultSyncInterval = time.Second
+)
+
+// Durability is how often a Roller commits the log file to stable storage.
+type Durability string
+
+var (
+	// DurabilityNever leaves it to the operating system.
+	DurabilityNever Durability = "never"
+	// DurabilityWrite syncs after every write.
+	DurabilityWrite Durability = "write"
+	// DurabilityBytes syncs once Options.SyncBytes have been written since
+	// the last sync.
+	DurabilityBytes Durability = "bytes"
+	// DurabilityInterval syncs every Options.SyncInterval, if anything was
+	// written since the last sync.
+	DurabilityInterval Durability = "interval"
+)
+
+func IsLegalDurability(d Durability) bool {
+	return d == "" || d == DurabilityNever || d == DurabilityWrite || d == DurabilityBytes || d == DurabilityInterval
+}
+
+// fileSync exists so it can be mocked out by tests.
+var fileSync = (*os.File).Sync
+
+// durable reports whether the log file is synced by the roller.
+func (r *Roller) durable() bool {
+	return r.durability != "" && r.durability != DurabilityNever
+}
+
+// syncAfterWrite counts n bytes written to the log file, and syncs it if the
+// durability policy says so. The caller holds r.mu.
+func (r *Roller) syncAfterWrite(n int) error {
+	r.unsynced += int64(n)
+	switch {
+	case r.durability == DurabilityWrite:
+	case r.durability == DurabilityBytes && r.unsynced >= r.syncBytes:
+	default:
+		return nil
+	}
+	return r.syncFile()
+}
+
+// syncFile syncs the log file, if it is open. The caller holds r.mu.
+func (r *Roller) syncFile() error {
+	if r.file == nil {
+		return nil
+	}
+	r.unsynced = 0
+	return fileSync(r.file)
+}
+
+// startSyncer starts the goroutine syncing the log file every syncInterval.
+func (r *Roller) startSyncer() {
+	r.syncStop = make(chan struct{})
+	r.syncDone = make(chan struct{})
+	go r.runSyncer()
+}
+
+// stopSyncer stops the syncer goroutine, if it was started, and waits for it
+// to exit. It must not be called with r.mu held.
+func (r *Roller) stopSyncer() {
+	if r.syncStop == nil {
+		return
+	}
+	r.stopSync.Do(func() {
+		close(r.syncStop)
+	})
+	<-r.syncDone
+}
+
+// runSyncer syncs the log file every syncInterval if anything was written to
+// it since the last sync, until stopped.
+func (r *Roller) runSyncer() {
+	defer close(r.syncDone)
+	ticker := time.NewTicker(r.syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.syncStop:
+			return
+		case <-ticker.C:
+		}
+		r.mu.Lock()
+		if r.unsynced > 0 {
+			// what am I going to do, log this?
+			_ = r.syncFile()
+		}
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,105 @@
+package lumberjack
+
+import (
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countSyncs makes fileSync count its calls, returning the count and a func
+// undoing it.
+func countSyncs() (*int64, func()) {
+	var syncs int64
+	fileSync = func(f *os.File) error {
+		atomic.AddInt64(&syncs, 1)
+		return f.Sync()
+	}
+	return &syncs, func() { fileSync = (*os.File).Sync }
+}
+
+func TestDurability(t *testing.T) {
+	currentTime = fakeTime
+	syncs, undo := countSyncs()
+	defer undo()
+
+	tests := []struct {
+		opt   Options
+		syncs int64
+	}{
+		{Options{}, 0},
+		{Options{Durability: DurabilityWrite}, 4},
+		{Options{Durability: DurabilityBytes, SyncBytes: 10}, 1},
+	}
+	for _, test := range tests {
+		dir := makeTempDir("TestDurability", t)
+		defer os.RemoveAll(dir)
+
+		opt := test.opt
+		opt.MaxSize = 100
+		l, err := NewRoller(logFile(dir), &opt)
+		isNil(err, t)
+
+		atomic.StoreInt64(syncs, 0)
+		for _, s := range []string{"boo!", "foooooo!", "baaar!", "ba"} {
+			_, err := l.Write([]byte(s))
+			isNil(err, t)
+		}
+		equals(test.syncs, atomic.LoadInt64(syncs), t)
+		isNil(l.Close(), t)
+	}
+
+	_, err := NewRoller(logFile(os.TempDir()), &Options{Durability: "sometimes"})
+	notNil(err, t)
+}
+
+func TestDurabilityRotate(t *testing.T) {
+	currentTime = fakeTime
+	syncs, undo := countSyncs()
+	defer undo()
+	dir := makeTempDir("TestDurabilityRotate", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{Durability: DurabilityBytes, SyncBytes: 100})
+	isNil(err, t)
+	defer l.Close()
+
+	_, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+	equals(int64(0), atomic.LoadInt64(syncs), t)
+	// the log file is synced before it becomes a backup
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	equals(int64(1), atomic.LoadInt64(syncs), t)
+	existsWithContent(backupFile(dir), []byte("boo!"), t)
+
+	isNil(l.Sync(), t)
+	equals(int64(2), atomic.LoadInt64(syncs), t)
+	isNil(l.Close(), t)
+	equals(int64(3), atomic.LoadInt64(syncs), t)
+}
+
+func TestDurabilityInterval(t *testing.T) {
+	currentTime = fakeTime
+	syncs, undo := countSyncs()
+	defer undo()
+	dir := makeTempDir("TestDurabilityInterval", t)
+	defer os.RemoveAll(dir)
+
+	l, err := NewRoller(logFile(dir), &Options{Durability: DurabilityInterval, SyncInterval: 10 * time.Millisecond})
+	isNil(err, t)
+	defer l.Close()
+
+	_, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+	equals(int64(0), atomic.LoadInt64(syncs), t)
+	for i := 0; i < 100 && atomic.LoadInt64(syncs) == 0; i++ {
+		<-time.After(10 * time.Millisecond)
+	}
+	equals(int64(1), atomic.LoadInt64(syncs), t)
+
+	// nothing new to sync
+	<-time.After(50 * time.Millisecond)
+	equals(int64(1), atomic.LoadInt64(syncs), t)
+}
@@ -3,4 +3,8 @@ package lumberjack
 type Hook struct {
 	// call after rotate complete
 	AfterRotate func(filepath string)
+	// call when the free space of the log file's or the backups'
+	// filesystem drops below MinFreeSpace, or below CriticalFreeSpace if
+	// critical is true
+	DiskSpaceLow func(free int64, critical bool)
 }
@@ -18,13 +18,16 @@
 package lumberjack
 
 import (
-	"compress/gzip"
+	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -85,46 +88,184 @@ func NewRoller(filename string, opt *Options) (*Roller, error) {
 		return nil, errors.New("filename cannot be empty")
 	}
 	r := &Roller{
-		filename:            filename,
-		maxSize:             defaultMaxSize,
-		disableRotateByTime: true,
+		filename: filename,
+		maxSize:  defaultMaxSize,
 	}
+	rotateByTime, enforceMaxSize := false, false
+	var timePolicy RotationPolicy
 	if opt != nil {
 		r.maxAge = opt.MaxAge
 		r.maxBackups = opt.MaxBackups
+		r.maxTotalSize = opt.MaxTotalSize
+		r.weekStart = opt.WeekStart
+		if len(opt.Retention) > 0 {
+			tiers, err := checkRetention(opt.Retention)
+			if err != nil {
+				return nil, err
+			}
+			r.retention = tiers
+		}
+		r.totalSizeWithActive = opt.MaxTotalSizeIncludesActive
+		r.minFreeSpace = opt.MinFreeSpace
+		r.criticalFreeSpace = opt.CriticalFreeSpace
+		if !IsLegalDiskFullMode(opt.DiskFullMode) {
+			return nil, errors.New("disk full mode is illegal")
+		}
+		if opt.DiskFullMode == DiskFullFallback && opt.FallbackPath == "" {
+			return nil, errors.New("DiskFullFallback needs a FallbackPath")
+		}
+		r.diskFullMode = opt.DiskFullMode
+		if !IsLegalOverflowPolicy(opt.OverflowPolicy) {
+			return nil, errors.New("overflow policy is illegal")
+		}
+		if !IsLegalOversizedWrite(opt.OversizedWrite) {
+			return nil, errors.New("oversized write is illegal")
+		}
+		if opt.OversizedWrite == OversizedOverflowFile && opt.OversizedPath == "" {
+			return nil, errors.New("OversizedOverflowFile needs an OversizedPath")
+		}
+		r.oversized = opt.OversizedWrite
+		r.oversizedPath = opt.OversizedPath
+		if !IsLegalDurability(opt.Durability) {
+			return nil, errors.New("durability is illegal")
+		}
+		r.durability = opt.Durability
+		r.syncBytes = opt.SyncBytes
+		r.syncInterval = opt.SyncInterval
+		r.fallbackPath = opt.FallbackPath
 		r.localTime = opt.LocalTime
 		r.compress = opt.Compress
+		r.compressor = opt.Compressor
+		r.compressAfter = opt.CompressAfter
+		r.compressAfterAge = opt.CompressAfterAge
+		r.compressWorkers = opt.CompressWorkers
+		r.compressNice = opt.CompressNice
+		r.manifest = opt.Manifest
+		r.delimiter = []byte(opt.RecordDelimiter)
+		r.throttle = newThrottle(opt.CompressRateLimit)
+		if r.compressor == nil {
+			r.compressor = GzipCompressor{Level: opt.CompressLevel}
+		}
 		r.maxSize = opt.MaxSize
 		if !IsLegalRotateType(opt.RotateType) {
 			return nil, errors.New("rotate type is illegal")
 		}
-		r.disableRotateByTime = (opt.RotateType == RotateDateNotNeed || opt.RotateType == RotateSize)
-		if !r.disableRotateByTime {
+		rotateByTime = !(opt.RotateType == RotateDateNotNeed || opt.RotateType == RotateSize)
+		enforceMaxSize = opt.EnforceMaxSize
+		if rotateByTime {
 			if opt.RotateTime == 0 {
 				opt.RotateTime = 1
 			}
 			if opt.RotateType == RotateHourly && 24/opt.RotateTime <= 0 {
 				return nil, errors.New("RotateTime must be a divisor of 24")
 			}
-			if opt.RotateType == RotateDaily {
-				r.maxAge = 24 * time.Hour * opt.MaxAge
-			} else if opt.RotateType == RotateHourly {
-				r.maxAge = time.Hour * opt.MaxAge
-			} else if opt.RotateType == RotateMinute {
-				r.maxAge = time.Minute * opt.MaxAge
+			// a MaxAge below one second is a number of rotate periods
+			if opt.MaxAge < time.Second && opt.RotateType.period() > 0 {
+				r.maxAge = opt.RotateType.period() * opt.MaxAge
+			}
+			// cron periods vary in length, so there is no count to scale
+			if opt.RotateType == RotateCron && opt.MaxAge > 0 && opt.MaxAge < time.Second {
+				return nil, errors.New("MaxAge must be a duration of at least a second with RotateCron")
+			}
+			if opt.RotateType == RotateCron {
+				p, err := NewCronPolicy(opt.RotateCron, opt.LocalTime)
+				if err != nil {
+					return nil, fmt.Errorf("invalid RotateCron: %w", err)
+				}
+				timePolicy = p
+			} else {
+				timePolicy = TimePolicy{opt.RotateType, opt.RotateTime, opt.LocalTime, opt.WeekStart}
 			}
 		}
 		r.rotateType = opt.RotateType
 		r.rotateTime = opt.RotateTime
+		r.sequenced = rotateByTime && enforceMaxSize
+		r.numberedBackups = opt.NumberedBackups
+		r.archiveDir = opt.ArchiveDir
+		r.partition = opt.PartitionLayout
+		r.skipEmpty = opt.SkipEmptyRotate
 		r.Hook = opt.Hook
+		if opt.NumberedBackups && opt.BackupNameTemplate != "" {
+			return nil, errors.New("NumberedBackups can't be used with BackupNameTemplate")
+		}
+		if opt.NumberedBackups && opt.PartitionLayout != "" {
+			return nil, errors.New("NumberedBackups can't be used with PartitionLayout")
+		}
+		if p := opt.PartitionLayout; strings.HasPrefix(p, "/") || strings.Contains("/"+p+"/", "/../") {
+			return nil, fmt.Errorf("partition layout %q must stay inside the backup directory", p)
+		}
+		if r.partition != "" {
+			r.partitionDirs = layoutDirs(r.partition)
+		}
+		if opt.BackupNameTemplate != "" {
+			codec := r.codec()
+			base := filepath.Base(r.newFilename())
+			ext := filepath.Ext(base)
+			tmpl, err := parseBackupTemplate(opt.BackupNameTemplate, base[:len(base)-len(ext)], ext, codec.layout, codec.loc)
+			if err != nil {
+				return nil, err
+			}
+			r.template = tmpl
+		}
 	}
 	if r.maxSize <= 0 {
 		r.maxSize = defaultMaxSize
 	}
-	err := r.openExistingOrNew(0)
+	if r.syncBytes <= 0 {
+		r.syncBytes = defaultSyncBytes
+	}
+	if r.syncInterval <= 0 {
+		r.syncInterval = defaultSyncInterval
+	}
+	if r.compressor == nil {
+		r.compressor = GzipCompressor{}
+	}
+	// a compressor which can't compress, e.g. for an invalid CompressLevel,
+	// would otherwise only fail in the mill
+	w, err := r.compressor.NewWriter(ioutil.Discard)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressor: %w", err)
+	}
+	w.Close()
+	RegisterCompressor(r.compressor)
+	// writes are limited in time based rotation too
+	r.maxWrite = r.maxSize
+	switch {
+	case opt != nil && opt.RotationPolicy != nil:
+		r.policy = opt.RotationPolicy
+		if opt.MaxSize <= 0 {
+			r.maxSize = 0
+			r.maxWrite = 0
+		}
+	case rotateByTime && enforceMaxSize:
+		r.policy = Any(timePolicy, SizePolicy{r.maxSize})
+	case rotateByTime:
+		r.policy = timePolicy
+		r.maxSize = 0
+	default:
+		r.policy = SizePolicy{r.maxSize}
+	}
+	if err := r.recoverCompression(); err != nil {
+		return nil, fmt.Errorf("can't recover backups: %w", err)
+	}
+	if r.numberedBackups {
+		if err := r.recoverBackups(); err != nil {
+			return nil, fmt.Errorf("can't recover backups: %w", err)
+		}
+	}
+	err = r.openExistingOrNew(0)
 	if err != nil {
 		return nil, fmt.Errorf("can't open file: %w", err)
 	}
+	if opt != nil && opt.ScheduledRotate {
+		r.startScheduler()
+	}
+	if r.durability == DurabilityInterval {
+		r.startSyncer()
+	}
+	if opt != nil && opt.Async {
+		r.startAsync(opt.BufferSize, opt.FlushInterval, opt.OverflowPolicy, opt.SampleRate)
+	}
 	return r, nil
 }
 
@@ -138,10 +279,20 @@ func NewRoller(filename string, opt *Options) (*Roller, error) {
 // Backups use the log file name given to Roller, in the form
 // `name-timestamp.ext` where name is the filename without the extension,
 // timestamp is the time at which the log was rotated formatted with the
-// time.Time format of `2006-01-02T15-04-05.000` and the extension is the
-// original extension. For example, if your Roller.Filename is
+// time.Time format of `20060102150405` and the extension is the original
+// extension. For example, if your Roller.Filename is
 // `/var/log/foo/server.log`, a backup created at 6:30pm on Nov 11 2016 would
-// use the filename `/var/log/foo/server-2016-11-04T18-30-00.000.log`
+// use the filename `/var/log/foo/server-20161104183000.log`. When rotating by
+// time, timestamp is the period the backup covers, formatted as `20060102-15`
+// for hourly, `20060102` for daily and weekly, and `200601` for monthly
+// rotation. A `-N` sequence number follows the timestamp of backups of the
+// same period. Backups in any of these formats, or in the
+// `2006-01-02T15-04-05.000` format of lumberjack v2, are recognized when
+// cleaning up old log files.
+//
+// With Options.NumberedBackups, backups are instead named like logrotate's,
+// `server.log.1` being the most recent, and are shifted up on every rotation.
+// Backups are kept beside the log file unless Options.ArchiveDir is set.
 //
 // # Cleaning Up Old Log Files
 //
@@ -160,14 +311,44 @@ type Roller struct {
 	filename string
 
 	// maxSize is the maximum size in bytes of the log file before it gets
-	// rotated.
+	// rotated, or 0 if the log file is not rotated by size.
 	maxSize int64
+	// maxWrite is the maximum length of a single write, or 0 if there is none,
+	// and oversized what is done with longer writes, to oversizedPath for
+	// OversizedOverflowFile.
+	maxWrite      int64
+	oversized     OversizedWrite
+	oversizedPath string
 
 	// maxAge is the maximum time to retain old log files based on the timestamp
 	// encoded in their filename. The default is not to remove old log files
 	// based on age.
 	maxAge time.Duration
 
+	// maxTotalSize is the maximum size in bytes of all backups together, and
+	// of the current log file too if totalSizeWithActive is set. The default
+	// is not to limit it.
+	maxTotalSize        int64
+	totalSizeWithActive bool
+
+	// minFreeSpace and criticalFreeSpace are the free space thresholds of the
+	// disk guard, see Options.
+	minFreeSpace      int64
+	criticalFreeSpace int64
+	diskFullMode      DiskFullMode
+	fallbackPath      string
+	// disk is the last known state of the log file's filesystem, checked at
+	// diskCheckedAt.
+	disk          diskState
+	diskCheckedAt time.Time
+	// fallback is the file written to while the disk is full in
+	// DiskFullFallback mode.
+	fallback *os.File
+
+	// retention are the retention tiers, sorted by Within.
+	retention []RetentionTier
+	weekStart time.Weekday
+
 	// maxBackups is the maximum number of old log files to retain.  The default
 	// is to retain all old log files (though MaxAge may still cause them to get
 	// deleted.)
@@ -179,13 +360,44 @@ type Roller struct {
 	localTime bool
 
 	// compress determines if the rotated log files should be compressed
-	// using gzip. The default is not to perform compression.
-	compress bool
+	// using compressor. The default is not to perform compression.
+	compress   bool
+	compressor Compressor
+	// compressAfter and compressAfterAge leave the newest backups, and those
+	// younger than compressAfterAge, uncompressed.
+	compressAfter    int
+	compressAfterAge time.Duration
+	// compressWorkers is how many backups are compressed at once, at nice
+	// value compressNice, reading them at most at the rate of throttle.
+	compressWorkers int
+	compressNice    int
+	throttle        *throttle
+
+	// manifest records the backups in the manifest, see manifestFile.
+	manifest   bool
+	manifestMu sync.Mutex
+	// hasher is the SHA-256 of the log file's contents, if the roller keeps a
+	// manifest and has seen all of them.
+	hasher hash.Hash
 
 	rotateType RotateType
 	// if RotateType is RotateHourly, need make (24%RotateTime==0 && 24/RotateTime > 0)
-	rotateTime          uint // unit depends on RotateType
-	disableRotateByTime bool
+	rotateTime uint // unit depends on RotateType
+	// sequenced makes backups of the same period carry a sequence number.
+	sequenced bool
+	// numberedBackups names backups foo.log.1, foo.log.2, ... newest first.
+	numberedBackups bool
+	// archiveDir is where backups are kept, see backupDir.
+	archiveDir string
+	// partition is the time layout of the subdirectories backups are placed
+	// in, if any, and partitionDirs match their names.
+	partition     string
+	partitionDirs []*regexp.Regexp
+
+	// policy decides when the log file is rotated.
+	policy RotationPolicy
+	// template names the backups, if set.
+	template *backupTemplate
 
 	size int64
 	file *os.File
@@ -193,9 +405,38 @@ type Roller struct {
 
 	millCh    chan bool
 	startMill sync.Once
-
-	createdTimestamp int64
-	remainSeconds    int64
+	// millMu keeps the mill from running while backups are being moved.
+	millMu sync.Mutex
+
+	// skipEmpty keeps an empty log file in place on scheduled rotations.
+	skipEmpty    bool
+	scheduleStop chan struct{}
+	scheduleDone chan struct{}
+	stopSchedule sync.Once
+
+	// opened is when the current log file was opened.
+	opened time.Time
+
+	// async buffers writes, if they are asynchronous.
+	async *asyncWriter
+
+	// durability is how often the log file is synced, every syncBytes bytes
+	// or every syncInterval, and unsynced the bytes written since the last
+	// sync.
+	durability   Durability
+	syncBytes    int64
+	syncInterval time.Duration
+	unsynced     int64
+	syncStop     chan struct{}
+	syncDone     chan struct{}
+	stopSync     sync.Once
+
+	// delimiter ends the records written, if set, and partial is the
+	// incomplete record after the last one. lens is reused for the lengths of
+	// the records written.
+	delimiter []byte
+	partial   []byte
+	lens      []int
 
 	Hook *Hook
 }
@@ -211,25 +452,50 @@ var (
 // Write implements io.Writer.  If a write would cause the log file to be larger
 // than MaxSize, the file is closed, renamed to include a timestamp of the
 // current time, and a new log file is created using the original log file name.
-// If the length of the write is greater than MaxSize, an error is returned.
+// If the length of the write is greater than MaxSize, it is handled according
+// to Options.OversizedWrite, by default failing with ErrWriteTooLong.
 func (r *Roller) Write(p []byte) (n int, err error) {
-	writeLen := int64(len(p))
-	if r.disableRotateByTime && writeLen > r.maxSize {
-		return 0, fmt.Errorf(
-			"write length %d, max size %d: %w", writeLen, r.maxSize, ErrWriteTooLong,
-		)
+	// with a record delimiter, each record is checked on its own
+	if r.maxWrite > 0 && int64(len(p)) > r.maxWrite && len(r.delimiter) == 0 && r.rejectOversized() {
+		return 0, r.errWriteTooLong(len(p))
+	}
+
+	if r.async != nil {
+		return r.writeAsync(p)
 	}
 
 	defer r.mu.Unlock()
 	r.mu.Lock()
-	// 时间切割优先
-	if r.disableRotateByTime {
-		if r.size+writeLen > r.maxSize {
-			if err := r.rotate(); err != nil {
-				return 0, err
-			}
+	return r.write(p)
+}
+
+// write writes p to the log file, rotating it first if needed, or only once
+// a record is complete with a record delimiter. The caller holds r.mu.
+func (r *Roller) write(p []byte) (n int, err error) {
+	if len(r.delimiter) > 0 {
+		return r.writeDelimited(p)
+	}
+	return r.writeWhole(p)
+}
+
+// writeWhole writes p, made of whole records, to the log file, handling it
+// as an oversized write if it is longer than maxWrite. The caller holds r.mu.
+func (r *Roller) writeWhole(p []byte) (n int, err error) {
+	if r.maxWrite > 0 && int64(len(p)) > r.maxWrite {
+		return r.writeOversized(p)
+	}
+	return r.writeFile(p)
+}
+
+// writeFile writes p to the log file, rotating it first if needed. The caller
+// holds r.mu.
+func (r *Roller) writeFile(p []byte) (n int, err error) {
+	if r.guardDisk() && r.checkDisk() == diskCritical {
+		if n, done, err := r.writeDiskFull(p); done {
+			return n, err
 		}
-	} else if r.needRotateByDate() {
+	}
+	if r.policy.ShouldRotate(r.state(int64(len(p)))) {
 		if err := r.rotate(); err != nil {
 			return 0, err
 		}
@@ -237,24 +503,68 @@ func (r *Roller) Write(p []byte) (n int, err error) {
 
 	n, err = r.file.Write(p)
 	r.size += int64(n)
+	if r.hasher != nil {
+		r.hasher.Write(p[:n])
+	}
+	if err == nil {
+		err = r.syncAfterWrite(n)
+	}
 
 	return n, err
 }
 
-// Close implements io.Closer, and closes the current logfile.
+// Sync writes everything accepted by Write to the log file, if writes are
+// asynchronous, and commits the log file to stable storage.
+func (r *Roller) Sync() error {
+	if err := r.flushErr(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	if err := r.flushPartial(); err != nil {
+		return err
+	}
+	return r.syncFile()
+}
+
+// Close implements io.Closer, and closes the current logfile. It also stops
+// the scheduled rotations, if they were enabled. With asynchronous writes,
+// everything accepted by Write is written to the log file and committed to
+// stable storage first, and the Roller can't be written to anymore.
 func (r *Roller) Close() error {
+	r.stopScheduler()
+	errAsync := r.stopAsync()
+	r.stopSyncer()
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	return r.close()
+	if err := r.flushPartial(); errAsync == nil {
+		errAsync = err
+	}
+	if r.async != nil || r.durable() {
+		if err := r.syncFile(); errAsync == nil {
+			errAsync = err
+		}
+	}
+	if err := r.close(); err != nil {
+		return err
+	}
+	return errAsync
 }
 
-// close closes the file if it is open.
+// close closes the file, and the fallback file, if they are open.
 func (r *Roller) close() error {
+	errFallback := r.closeFallback()
 	if r.file == nil {
-		return nil
+		return errFallback
 	}
 	err := r.file.Close()
 	r.file = nil
+	if err == nil {
+		err = errFallback
+	}
 	return err
 }
 
@@ -264,6 +574,8 @@ func (r *Roller) close() error {
 // SIGHUP.  After rotating, this initiates compression and removal of old log
 // files according to the configuration.
 func (r *Roller) Rotate() error {
+	// buffered writes go to the log file being rotated
+	r.flush()
 	defer r.mu.Unlock()
 	r.mu.Lock()
 	return r.rotate()
@@ -273,6 +585,12 @@ func (r *Roller) Rotate() error {
 // (if it exists), opens a new file with the original filename, and then runs
 // post-rotation processing and removar.
 func (r *Roller) rotate() error {
+	if r.durable() {
+		// the backup keeps everything written
+		if err := r.syncFile(); err != nil {
+			return err
+		}
+	}
 	if err := r.close(); err != nil {
 		return err
 	}
@@ -293,32 +611,22 @@ func (r *Roller) openNew() error {
 
 	name := r.newFilename()
 	mode := os.FileMode(0644)
+	// backupDir is where the old logfile was moved to, if any
+	var backupDir string
 	info, err := osStat(name)
 	if err == nil {
 		// Copy the mode off the old logfile.
 		mode = info.Mode()
 		// move the existing file
-		dateStr := currentTime().Format(backupTimeFormat)
-		if !r.localTime {
-			dateStr = currentTime().UTC().Format(backupTimeFormat)
-		}
-		if r.rotateType == "hourly" {
-			dateStr = currentTime().Add(-1 * time.Hour).Format("20060102-15")
-		}
-		if r.rotateType == "hourly" && !r.localTime {
-			dateStr = currentTime().Add(-1 * time.Hour).UTC().Format("20060102-15")
-		}
-		if r.rotateType == "daily" {
-			dateStr = currentTime().Add(-1 * time.Hour).Format("20060102")
-		}
-		if r.rotateType == "daily" && !r.localTime {
-			dateStr = currentTime().Add(-1 * time.Hour).UTC().Format("20060102")
-		}
-		newname := backupName(name, dateStr)
-		if err := os.Rename(name, newname); err != nil {
-			return fmt.Errorf("can't rename log file: %w", err)
+		newname, err := r.archive(name)
+		if err != nil {
+			return err
 		}
-		if r.Hook != nil {
+		backupDir = filepath.Dir(newname)
+		// the backup is in place, a failure to record it shows up as an
+		// unrecorded backup in Verify
+		_ = r.recordRotate(newname, r.hasher)
+		if r.Hook != nil && r.Hook.AfterRotate != nil {
 			go r.Hook.AfterRotate(newname)
 		}
 		// this is a no-op anywhere but linux
@@ -336,10 +644,47 @@ func (r *Roller) openNew() error {
 	}
 	r.file = f
 	r.size = 0
-	r.calRotateCycle()
+	r.unsynced = 0
+	r.opened = currentTime()
+	r.hasher = nil
+	if r.manifest {
+		r.hasher = sha256.New()
+	}
+	// make the new file, and the rotation before it, last through a crash
+	syncDir(r.dir())
+	if backupDir != "" && backupDir != r.dir() {
+		syncDir(backupDir)
+	}
 	return nil
 }
 
+// archive moves the log file name to its backup, returning the name of the
+// backup.
+func (r *Roller) archive(name string) (string, error) {
+	if r.numberedBackups || r.nested() {
+		// don't race with the mill shifting backups or removing the
+		// directory the backup goes to
+		r.millMu.Lock()
+		defer r.millMu.Unlock()
+	}
+	var newname string
+	if r.numberedBackups {
+		if err := r.shiftBackups(); err != nil {
+			return "", err
+		}
+		newname = numberedName(r.archivedName(), 1)
+	} else {
+		newname = r.backupFilename(r.archivedName())
+	}
+	if err := os.MkdirAll(filepath.Dir(newname), 0755); err != nil {
+		return "", fmt.Errorf("can't make directories for backup: %w", err)
+	}
+	if err := moveFile(name, newname); err != nil {
+		return "", fmt.Errorf("can't rename log file: %w", err)
+	}
+	return newname, nil
+}
+
 // backupName creates a new filename from the given name, inserting a timestamp
 // between the filename and the extension, using the local time if requested
 // (otherwise UTC).
@@ -351,11 +696,74 @@ func backupName(name string, timestamp string) string {
 	return filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, timestamp, ext))
 }
 
+// backupFilename returns the name the current log file is moved to on
+// rotation, stamped with the time chosen by the rotation policy. A sequence
+// number is appended if the roller numbers its backups or the plain name is
+// already taken, compressed or not, so that several backups of the same period don't collide.
+func (r *Roller) backupFilename(name string) string {
+	t := r.policy.BackupTimestamp(r.state(0))
+	if newname := r.backupNameAt(name, t, 0); !r.sequenced && !r.backupExists(newname) {
+		return newname
+	}
+	for seq := 1; ; seq++ {
+		newname := r.backupNameAt(name, t, seq)
+		if !r.backupExists(newname) {
+			return newname
+		}
+	}
+}
+
+// backupNameAt returns the name of the backup of name stamped t, with sequence
+// number seq unless it is 0.
+func (r *Roller) backupNameAt(name string, t time.Time, seq int) string {
+	if r.partition != "" {
+		partition := filepath.FromSlash(t.In(r.codec().loc).Format(r.partition))
+		name = filepath.Join(filepath.Dir(name), partition, filepath.Base(name))
+	}
+	if r.template != nil {
+		return filepath.Join(filepath.Dir(name), filepath.FromSlash(r.template.format(t, seq)))
+	}
+	return backupName(name, r.codec().format(t, seq))
+}
+
+// backupExists reports whether a backup with the given name exists, either as
+// is or already compressed.
+func (r *Roller) backupExists(name string) bool {
+	if _, err := osStat(name); err == nil {
+		return true
+	}
+	for _, suffix := range compressSuffixes() {
+		if _, err := osStat(name + suffix); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// codec returns the codec for the timestamps in backup names.
+func (r *Roller) codec() backupCodec {
+	c := backupCodec{layout: backupTimeFormat, loc: time.UTC}
+	switch r.rotateType {
+	case RotateHourly:
+		c.layout = "20060102-15"
+	case RotateDaily, RotateWeekly:
+		c.layout = "20060102"
+	case RotateMonthly:
+		c.layout = "200601"
+	}
+	if r.localTime {
+		c.loc = time.Local
+	}
+	return c
+}
+
 // openExistingOrNew opens the logfile if it exists and if the current write
 // would not put it over MaxSize.  If there is no such file or the write would
 // put it over the MaxSize, a new file is created.
 func (r *Roller) openExistingOrNew(writeLen int64) error {
 	r.mill()
+	// the file may have changed since it was last open
+	r.hasher = nil
 
 	filename := r.newFilename()
 	info, err := osStat(filename)
@@ -365,10 +773,9 @@ func (r *Roller) openExistingOrNew(writeLen int64) error {
 	if err != nil {
 		return fmt.Errorf("error getting log file info: %w", err)
 	}
-	if r.disableRotateByTime {
-		if info.Size()+writeLen >= r.maxSize {
-			return r.rotate()
-		}
+	state := RotationState{Size: info.Size(), WriteLen: writeLen, Opened: currentTime(), Now: currentTime()}
+	if r.policy.ShouldRotate(state) {
+		return r.rotate()
 	}
 	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
@@ -378,7 +785,13 @@ func (r *Roller) openExistingOrNew(writeLen int64) error {
 	}
 	r.file = file
 	r.size = info.Size()
-	r.calRotateCycle()
+	r.unsynced = 0
+	r.opened = currentTime()
+	r.hasher = nil
+	if r.manifest {
+		// if it can't be read, the backup is hashed on rotation
+		r.hasher, _ = hashContents(filename)
+	}
 	return nil
 }
 
@@ -394,76 +807,25 @@ func (r *Roller) newFilename() string {
 // millRunOnce performs compression and removal of stale log files.
 // Log files are compressed if enabled via configuration and old log
 // files are removed, keeping at most r.MaxBackups files, as long as
-// none of them are older than MaxAge.
+// none of them are older than MaxAge, the retention tiers keep them and
+// they fit in MaxTotalSize.
 func (r *Roller) millRunOnce() error {
-	if r.maxBackups == 0 && r.maxAge == 0 && !r.compress {
+	if !r.maintained() {
 		return nil
 	}
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
 
-	files, err := r.oldLogFiles()
+	results, err := r.runMaintenance(context.Background())
 	if err != nil {
 		return err
 	}
-
-	var compress, remove []logInfo
-
-	if r.maxBackups > 0 && r.maxBackups < len(files) {
-		preserved := make(map[string]bool)
-		var remaining []logInfo
-		for _, f := range files {
-			// Only count the uncompressed log file or the
-			// compressed log file, not both.
-			fn := f.Name()
-			if strings.HasSuffix(fn, compressSuffix) {
-				fn = fn[:len(fn)-len(compressSuffix)]
-			}
-			preserved[fn] = true
-
-			if len(preserved) > r.maxBackups {
-				remove = append(remove, f)
-			} else {
-				remaining = append(remaining, f)
-			}
-		}
-		files = remaining
-	}
-	if r.maxAge > 0 {
-		cutoff := currentTime().Add(-1 * r.maxAge)
-
-		var remaining []logInfo
-		for _, f := range files {
-			if f.timestamp.Before(cutoff) {
-				remove = append(remove, f)
-			} else {
-				remaining = append(remaining, f)
-			}
-		}
-		files = remaining
-	}
-
-	if r.compress {
-		for _, f := range files {
-			if !strings.HasSuffix(f.Name(), compressSuffix) {
-				compress = append(compress, f)
-			}
+	for _, res := range results {
+		if res.Err != nil {
+			return res.Err
 		}
 	}
-
-	for _, f := range remove {
-		errRemove := os.Remove(filepath.Join(r.dir(), f.Name()))
-		if err == nil && errRemove != nil {
-			err = errRemove
-		}
-	}
-	for _, f := range compress {
-		fn := filepath.Join(r.dir(), f.Name())
-		errCompress := compressLogFile(fn, fn+compressSuffix)
-		if err == nil && errCompress != nil {
-			err = errCompress
-		}
-	}
-
-	return err
+	return nil
 }
 
 // millRun runs in a goroutine to manage post-rotation compression and removal
@@ -488,28 +850,32 @@ func (r *Roller) mill() {
 	}
 }
 
-// oldLogFiles returns the list of backup log files stored in the same
-// directory as the current log file, sorted by ModTime
+// oldLogFiles returns the list of backup log files stored in the backup
+// directory, or below it if backups are partitioned or the backup name
+// template has subdirectories, sorted by ModTime
 func (r *Roller) oldLogFiles() ([]logInfo, error) {
-	files, err := ioutil.ReadDir(r.dir())
+	if r.numberedBackups {
+		return r.numberedLogFiles()
+	}
+	if r.nested() {
+		return r.walkLogFiles()
+	}
+	files, err := ioutil.ReadDir(r.backupDir())
+	if os.IsNotExist(err) {
+		// nothing has been archived yet
+		return nil, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("can't read log file directory: %s", err)
 	}
 	logFiles := []logInfo{}
 
-	prefix, ext := r.prefixAndExt()
-
 	for _, f := range files {
 		if f.IsDir() {
 			continue
 		}
-		if t, err := r.timeFromName(f.Name(), prefix, ext); err == nil {
-			logFiles = append(logFiles, logInfo{t, f})
-			continue
-		}
-		if t, err := r.timeFromName(f.Name(), prefix, ext+compressSuffix); err == nil {
-			logFiles = append(logFiles, logInfo{t, f})
-			continue
+		if t, seq, err := r.parseBackup(f.Name()); err == nil {
+			logFiles = append(logFiles, logInfo{t, seq, filepath.Join(r.backupDir(), f.Name()), f})
 		}
 		// error parsing means that the suffix at the end was not generated
 		// by lumberjack, and therefore it's not a backup file.
@@ -520,18 +886,162 @@ func (r *Roller) oldLogFiles() ([]logInfo, error) {
 	return logFiles, nil
 }
 
+// walkLogFiles is oldLogFiles for backups in subdirectories.
+func (r *Roller) walkLogFiles() ([]logInfo, error) {
+	logFiles := []logInfo{}
+	err := r.walkBackupDir(func(path string, f os.FileInfo) error {
+		if t, seq, err := r.parseBackupPath(path); err == nil {
+			logFiles = append(logFiles, logInfo{t, seq, path, f})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't read log file directory: %s", err)
+	}
+
+	sort.Sort(byFormatTime(logFiles))
+
+	return logFiles, nil
+}
+
+// walkBackupDir calls fn with each file in the backup directory, and in the
+// subdirectories backups can be nested in: those whose names fit the
+// partition layout and the backup name template, as deep as they go. Other
+// directories, which could be a whole unrelated tree next to the log file,
+// are neither read nor have to be readable.
+func (r *Roller) walkBackupDir(fn func(path string, f os.FileInfo) error) error {
+	root := r.backupDir()
+	return filepath.Walk(root, func(path string, f os.FileInfo, err error) error {
+		if path == root {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if err != nil {
+			if f != nil && f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !f.IsDir() {
+			return fn(path, f)
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || !r.fitsBackupDir(strings.Split(filepath.ToSlash(rel), "/")) {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// fitsBackupDir reports whether backups can be nested in the directory with
+// the given names below the backup directory: the partitions, followed by
+// the directories of the backup name template, or just the latter for
+// backups from before partitioning was enabled.
+func (r *Roller) fitsBackupDir(names []string) bool {
+	var tmplDirs []*regexp.Regexp
+	if r.template != nil {
+		tmplDirs = r.template.dirs
+	}
+	return namesMatch(names, append(r.partitionDirs[:len(r.partitionDirs):len(r.partitionDirs)], tmplDirs...)) ||
+		namesMatch(names, tmplDirs)
+}
+
+// namesMatch reports whether each of names matches the pattern at the same
+// level in dirs.
+func namesMatch(names []string, dirs []*regexp.Regexp) bool {
+	if len(names) > len(dirs) {
+		return false
+	}
+	for i, name := range names {
+		if !dirs[i].MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseBackupPath is parseBackup for the file at path. A backup is either in
+// the partition of its own timestamp, or, from before partitioning was
+// enabled, in the backup directory itself.
+func (r *Roller) parseBackupPath(path string) (time.Time, int, error) {
+	rel, err := filepath.Rel(r.backupDir(), path)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	rel = filepath.ToSlash(rel)
+	if strings.HasPrefix(rel, "../") {
+		return time.Time{}, 0, fmt.Errorf("%s is outside the backup directory", path)
+	}
+	var partition string
+	if n := strings.Count(r.partition, "/") + 1; r.partition != "" && strings.Count(rel, "/") >= n {
+		parts := strings.SplitN(rel, "/", n+1)
+		partition, rel = strings.Join(parts[:n], "/"), parts[n]
+	}
+	t, seq, err := r.parseBackup(rel)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if partition != "" && partition != t.In(r.codec().loc).Format(r.partition) {
+		return time.Time{}, 0, fmt.Errorf("%s is not in the partition of its timestamp", path)
+	}
+	return t, seq, nil
+}
+
+// nested reports whether backups are placed in subdirectories of the backup
+// directory.
+func (r *Roller) nested() bool {
+	return r.partition != "" || r.template != nil && r.template.nested()
+}
+
+// removeEmptyDirs removes dir and its parents up to the backup directory, as
+// long as they are empty.
+func (r *Roller) removeEmptyDirs(dir string) {
+	root := filepath.Clean(r.backupDir())
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		// fails unless the directory is empty
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// parseBackup extracts the time and sequence number from the name of a
+// backup, relative to the backup directory. An error means the file is not
+// a backup.
+func (r *Roller) parseBackup(name string) (time.Time, int, error) {
+	if r.template != nil {
+		return r.template.parse(trimCompressed(name))
+	}
+	prefix, ext := r.prefixAndExt()
+	if t, seq, err := r.parseBackupName(name, prefix, ext); err == nil {
+		return t, seq, nil
+	}
+	return r.parseBackupName(name, prefix, ext+compressedSuffix(name))
+}
+
 // timeFromName extracts the formatted time from the filename by stripping off
 // the filename's prefix and extension. This prevents someone's filename from
 // confusing time.parse.
 func (r *Roller) timeFromName(filename, prefix, ext string) (time.Time, error) {
+	t, _, err := r.parseBackupName(filename, prefix, ext)
+	return t, err
+}
+
+// parseBackupName extracts the formatted time and, for backups of the same
+// period, the sequence number from the filename.
+func (r *Roller) parseBackupName(filename, prefix, ext string) (time.Time, int, error) {
 	if !strings.HasPrefix(filename, prefix) {
-		return time.Time{}, errors.New("mismatched prefix")
+		return time.Time{}, 0, errors.New("mismatched prefix")
 	}
 	if !strings.HasSuffix(filename, ext) {
-		return time.Time{}, errors.New("mismatched extension")
+		return time.Time{}, 0, errors.New("mismatched extension")
 	}
-	ts := filename[len(prefix) : len(filename)-len(ext)]
-	return time.Parse(backupTimeFormat, ts)
+	if len(filename) < len(prefix)+len(ext) {
+		return time.Time{}, 0, errors.New("missing timestamp")
+	}
+	return r.codec().parse(filename[len(prefix) : len(filename)-len(ext)])
 }
 
 // dir returns the directory for the current filename.
@@ -548,37 +1058,47 @@ func (r *Roller) prefixAndExt() (prefix, ext string) {
 	return prefix, ext
 }
 
-func (r *Roller) calRotateCycle() {
-	if r.disableRotateByTime {
-		return
+// state describes the current log file to the rotation policy.
+func (r *Roller) state(writeLen int64) RotationState {
+	return RotationState{
+		Size:     r.size,
+		WriteLen: writeLen,
+		Opened:   r.opened,
+		Now:      currentTime(),
 	}
-	n := currentTime()
-	r.createdTimestamp = n.Unix()
-	r.remainSeconds = calRemainderSecondToNextRotateTime(n, r.rotateType, int(r.rotateTime), r.localTime)
-}
-
-func (r *Roller) needRotateByDate() bool {
-	if r.disableRotateByTime {
-		return false
-	}
-	return currentTime().Unix()-r.createdTimestamp >= r.remainSeconds
 }
 
 // 根据当前时间，周期单位 ，周期数量 计算举例下个周期剩余的秒数
-func calRemainderSecondToNextRotateTime(now time.Time, rotateType RotateType, rotateTime int, isLocal bool) int64 {
+// weekStart 为按周切割时每周的第一天, 默认周日
+func calRemainderSecondToNextRotateTime(now time.Time, rotateType RotateType, rotateTime int, isLocal bool, weekStart ...time.Weekday) int64 {
 	local := time.Local
 	if !isLocal {
 		local = time.UTC
 	}
+	// 以月为周期, 下个切割点为若干个月后的1号0点
+	if rotateType == RotateMonthly {
+		y, m, _ := now.In(local).Date()
+		zeroClock := time.Date(y, m+time.Month(rotateTime), 1, 0, 0, 0, 0, local)
+		return zeroClock.Unix() - now.Unix()
+	}
+	// 以周为周期, 下个切割点为若干周后每周第一天的0点
+	if rotateType == RotateWeekly {
+		first := time.Sunday
+		if len(weekStart) > 0 {
+			first = weekStart[0]
+		}
+		t := now.In(local)
+		days := (int(first)-int(t.Weekday())+6)%7 + 1 + 7*(rotateTime-1)
+		y, m, d := t.Date()
+		zeroClock := time.Date(y, m, d+days, 0, 0, 0, 0, local)
+		return zeroClock.Unix() - now.Unix()
+	}
 	// 以天为周期
 	if rotateType == RotateDaily {
-		rotateDate := now.Add(time.Hour * time.Duration(int64(rotateTime)*24))
-		y, m, d := rotateDate.Date()
-		if !isLocal {
-			y, m, d = rotateDate.UTC().Date()
-		}
+		// 按日历日累加, 夏令时切换当天不是24小时
+		y, m, d := now.In(local).Date()
 		// 重置为0点
-		zeroClock := time.Date(y, m, d, 0, 0, 0, 0, local)
+		zeroClock := time.Date(y, m, d+rotateTime, 0, 0, 0, 0, local)
 		return zeroClock.Unix() - now.Unix()
 	}
 	// 以小时为周期
@@ -590,16 +1110,11 @@ func calRemainderSecondToNextRotateTime(now time.Time, rotateType RotateType, ro
 		// 下一个切割时间点
 		nextRotateHour := ((currentHour / rotateTime) + 1) * rotateTime
 		// 如果下一个时间切割点大于24小时，那么第二天的0点就是下一个切割点
-		rotateDate := now
+		y, m, d := now.In(local).Date()
 		if nextRotateHour >= 24 {
-			rotateDate = rotateDate.Add(time.Hour * 24)
+			d++
 			nextRotateHour = 0
 		}
-		y, m, d := rotateDate.Date()
-		// UTC
-		if !isLocal {
-			y, m, d = rotateDate.UTC().Date()
-		}
 		zeroClock := time.Date(y, m, d, nextRotateHour, 0, 0, 0, local)
 		return zeroClock.Unix() - now.Unix()
 	}
@@ -639,9 +1154,13 @@ func calRemainderSecondToNextRotateTime(now time.Time, rotateType RotateType, ro
 
 }
 
-// compressLogFile compresses the given log file, removing the
-// uncompressed log file if successfur.
-func compressLogFile(src, dst string) (err error) {
+// compressLogFile compresses the given log file with c, removing the
+// uncompressed log file if successfur. It is read at most at the rate of t,
+// unless t is nil, and compression is abandoned once ctx is done. The
+// compressed file is written to a temporary file and synced before it is
+// renamed to dst, so that dst is complete if it exists. An existing dst is
+// left alone, failing the compression.
+func compressLogFile(ctx context.Context, src, dst string, c Compressor, t *throttle) (err error) {
 	f, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %v", err)
@@ -653,33 +1172,35 @@ func compressLogFile(src, dst string) (err error) {
 		return fmt.Errorf("failed to stat log file: %v", err)
 	}
 
-	if err := chown(dst, fi); err != nil {
-		return fmt.Errorf("failed to chown compressed log file: %v", err)
-	}
-
+	tmp := dst + tmpSuffix
 	// If this file already exists, we presume it was created by
 	// a previous attempt to compress the log file.
-	gzf, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode())
+	gzf, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode())
 	if err != nil {
 		return fmt.Errorf("failed to open compressed log file: %v", err)
 	}
 	defer gzf.Close()
 
-	gz := gzip.NewWriter(gzf)
-
 	defer func() {
 		if err != nil {
-			os.Remove(dst)
-			err = fmt.Errorf("failed to compress log file: %v", err)
+			os.Remove(tmp)
+			err = fmt.Errorf("failed to compress log file: %w", err)
 		}
 	}()
 
-	if _, err := io.Copy(gz, f); err != nil {
+	gz, err := c.NewWriter(gzf)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(gz, &maintenanceReader{ctx, f, t}); err != nil {
 		return err
 	}
 	if err := gz.Close(); err != nil {
 		return err
 	}
+	if err := gzf.Sync(); err != nil {
+		return err
+	}
 	if err := gzf.Close(); err != nil {
 		return err
 	}
@@ -687,6 +1208,21 @@ func compressLogFile(src, dst string) (err error) {
 	if err := f.Close(); err != nil {
 		return err
 	}
+	// keep the modification time, numbered backups are aged by it
+	if err := os.Chtimes(tmp, fi.ModTime(), fi.ModTime()); err != nil {
+		return err
+	}
+	// never replace another backup, which a rename would do silently
+	if _, err := osStat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(dst))
+	if err := chownFile(dst, fi); err != nil {
+		return fmt.Errorf("failed to chown compressed log file: %v", err)
+	}
 	if err := os.Remove(src); err != nil {
 		return err
 	}
@@ -698,13 +1234,19 @@ func compressLogFile(src, dst string) (err error) {
 // timestamp.
 type logInfo struct {
 	timestamp time.Time
+	seq       int
+	path      string
 	os.FileInfo
 }
 
-// byFormatTime sorts by newest time formatted in the name.
+// byFormatTime sorts by newest time formatted in the name, then by the highest
+// sequence number.
 type byFormatTime []logInfo
 
 func (b byFormatTime) Less(i, j int) bool {
+	if b[i].timestamp.Equal(b[j].timestamp) {
+		return b[i].seq > b[j].seq
+	}
 	return b[i].timestamp.After(b[j].timestamp)
 }
 
@@ -9,6 +9,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -152,6 +153,8 @@ func TestTimeRotateDaily(t *testing.T) {
 	b := []byte("boo!")
 	b2 := []byte("foooooo!")
 	t.Run("daily", func(t *testing.T) {
+		// start at midnight so that rotations happen right on the day boundary
+		fakeCurrentTime = time.Date(2022, 10, 1, 0, 0, 0, 0, time.Local)
 		dir := makeTempDir("TestDateRotate", t)
 		defer os.RemoveAll(dir)
 		keepMaxDay := 2
@@ -182,7 +185,7 @@ func TestTimeRotateDaily(t *testing.T) {
 			equals(len(b2), n, t)
 			existsWithContent(filename, b2, t)
 			fileCount(dir, 2, t)
-			existsWithContent(backupFile(dir, true), b, t)
+			existsWithContent(dailyBackupFile(dir, fakeTime().Add(-24*time.Hour)), b, t)
 		})
 		t.Run("check backup files", func(t *testing.T) {
 			t.Run("2 day later", func(t *testing.T) {
@@ -205,7 +208,8 @@ func TestTimeRotateDaily(t *testing.T) {
 				// we need to wait a little bit since the files get deleted on a different
 				// goroutine.
 				<-time.After(time.Millisecond * 100)
-				fileCount(dir, 3+1, t)
+				// the backup of the first day is older than 2 days now
+				fileCount(dir, 2+1, t)
 			})
 			t.Run("4 day later", func(t *testing.T) {
 				newFakeTime(24 * time.Hour)
@@ -216,13 +220,121 @@ func TestTimeRotateDaily(t *testing.T) {
 				// we need to wait a little bit since the files get deleted on a different
 				// goroutine.
 				<-time.After(time.Millisecond * 100)
-				fileCount(dir, 3+1, t)
+				fileCount(dir, 2+1, t)
 			})
 		})
 	})
 
 }
 
+func TestTimeRotateDailyWithMaxSize(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestTimeRotateDailyWithMaxSize", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	r, err := NewRoller(filename, &Options{
+		MaxSize:        10,
+		RotateType:     RotateDaily,
+		EnforceMaxSize: true,
+		LocalTime:      true,
+	})
+	isNil(err, t)
+	defer r.Close()
+	day := fakeTime()
+
+	b := []byte("boo!")
+	n, err := r.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+
+	_, err = r.Write([]byte("booooooooooooooo!"))
+	if !errors.Is(err, ErrWriteTooLong) {
+		t.Fatalf("expected error to be ErrWriteTooLong, but it was %#v", err)
+	}
+
+	// these exceed MaxSize within the same day
+	b2 := []byte("foooooo!")
+	n, err = r.Write(b2)
+	isNil(err, t)
+	equals(len(b2), n, t)
+	b3 := []byte("baaaaaar!")
+	n, err = r.Write(b3)
+	isNil(err, t)
+	equals(len(b3), n, t)
+
+	// and this one crosses into the next day
+	newFakeTime(24 * time.Hour)
+	b4 := []byte("baaaaaaz!")
+	n, err = r.Write(b4)
+	isNil(err, t)
+	equals(len(b4), n, t)
+
+	existsWithContent(filename, b4, t)
+	existsWithContent(dailyBackupFile(dir, day, 1), b, t)
+	existsWithContent(dailyBackupFile(dir, day, 2), b2, t)
+	existsWithContent(dailyBackupFile(dir, day, 3), b3, t)
+	fileCount(dir, 4, t)
+
+	files, err := r.oldLogFiles()
+	isNil(err, t)
+	equals(3, len(files), t)
+	for i, f := range files {
+		equals(3-i, f.seq, t)
+	}
+}
+
+func TestTimeRotateMonthly(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	dir := makeTempDir("TestTimeRotateMonthly", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	r, err := NewRoller(filename, &Options{
+		MaxAge:     1, // one month
+		RotateType: RotateMonthly,
+	})
+	isNil(err, t)
+	defer r.Close()
+
+	b := []byte("boo!")
+	n, err := r.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+
+	// still January
+	fakeCurrentTime = time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC)
+	n, err = r.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	fileCount(dir, 1, t)
+
+	// the January backup is just a month old, so the mill keeps it
+	fakeCurrentTime = time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	b2 := []byte("foooooo!")
+	n, err = r.Write(b2)
+	isNil(err, t)
+	equals(len(b2), n, t)
+	existsWithContent(filepath.Join(dir, "foobar-202601.log"), append(b, b...), t)
+
+	fakeCurrentTime = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	b3 := []byte("baaaaaar!")
+	n, err = r.Write(b3)
+	isNil(err, t)
+	equals(len(b3), n, t)
+	existsWithContent(filepath.Join(dir, "foobar-202602.log"), b2, t)
+
+	// we need to wait a little bit since the files get deleted on a different
+	// goroutine.
+	<-time.After(10 * time.Millisecond)
+
+	// the January backup is more than a month old
+	notExist(filepath.Join(dir, "foobar-202601.log"), t)
+	existsWithContent(filename, b3, t)
+	fileCount(dir, 2, t)
+}
+
 func TestTimeRotateHoures(t *testing.T) {
 	currentTime = fakeTime
 	b := []byte("boo!")
@@ -399,25 +511,27 @@ func TestMaxBackups(t *testing.T) {
 	// this will use the new fake time
 	fourthFilename := backupFile(dir)
 
-	// Create a log file that is/was being compressed - this should
-	// not be counted since both the compressed and the uncompressed
-	// log files still exist.
-	compLogFile := fourthFilename + compressSuffix
-	err = ioutil.WriteFile(compLogFile, []byte("compress"), 0644)
-	isNil(err, t)
-
 	// this will make us rotate again
 	b4 := []byte("baaaaaaz!")
 	n, err = r.Write(b4)
 	isNil(err, t)
 	equals(len(b4), n, t)
 
+	// Create a log file that is/was being compressed - this should
+	// not be counted since both the compressed and the uncompressed
+	// log files still exist. It is created after the rotation, since
+	// a compressed backup already taking the name would make the
+	// rotation pick the next one.
+	compLogFile := fourthFilename + compressSuffix
+	err = ioutil.WriteFile(compLogFile, []byte("compress"), 0644)
+	isNil(err, t)
+
 	existsWithContent(fourthFilename, b3, t)
 	existsWithContent(fourthFilename+compressSuffix, []byte("compress"), t)
 
-	// we need to wait a little bit since the files get deleted on a different
-	// goroutine.
-	<-time.After(time.Millisecond * 10)
+	// the mill may still be running on a different goroutine, run it once
+	// more to be sure it saw both.
+	isNil(r.millRunOnce(), t)
 
 	// We should have four things in the directory now - the 2 log files, the
 	// not log file, and the directory
@@ -551,6 +665,48 @@ func TestMaxAge(t *testing.T) {
 	existsWithContent(backupFile(dir), b2, t)
 }
 
+func TestMaxTotalSize(t *testing.T) {
+	currentTime = fakeTime
+
+	dir := makeTempDir("TestMaxTotalSize", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{MaxTotalSize: 12, MaxSize: 10})
+	isNil(err, t)
+	defer l.Close()
+
+	first := backupFile(dir)
+	for _, b := range []string{"boo!", "foooooo!", "baaar!"} {
+		n, err := l.Write([]byte(b))
+		isNil(err, t)
+		equals(len(b), n, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+	}
+
+	// the mill may still be running on a different goroutine, run it once
+	// more to be sure it is done.
+	isNil(l.millRunOnce(), t)
+
+	// the two most recent backups take up 14 bytes, so only the newest one
+	// fits
+	notExist(first, t)
+	existsWithContent(backupFile(dir), []byte("baaar!"), t)
+	fileCount(dir, 2, t)
+
+	// counting the current log file too, nothing fits besides it
+	isNil(l.Close(), t)
+	l2, err := NewRoller(filename, &Options{MaxTotalSize: 8, MaxTotalSizeIncludesActive: true, MaxSize: 10})
+	isNil(err, t)
+	defer l2.Close()
+	_, err = l2.Write([]byte("12345"))
+	isNil(err, t)
+	isNil(l2.millRunOnce(), t)
+	fileCount(dir, 1, t)
+	existsWithContent(filename, []byte("12345"), t)
+}
+
 func TestOldLogFiles(t *testing.T) {
 	currentTime = fakeTime
 
@@ -613,6 +769,86 @@ func TestTimeFromName(t *testing.T) {
 	}
 }
 
+func TestParseBackupName(t *testing.T) {
+	tests := []struct {
+		rotateType RotateType
+		filename   string
+		want       time.Time
+		wantSeq    int
+		wantErr    bool
+	}{
+		{RotateSize, "foo-20140504144433.log", time.Date(2014, 5, 4, 14, 44, 33, 0, time.UTC), 0, false},
+		{RotateSize, "foo-20140504144433-2.log", time.Date(2014, 5, 4, 14, 44, 33, 0, time.UTC), 2, false},
+		{RotateSize, "foo-20140504-14.log", time.Date(2014, 5, 4, 14, 0, 0, 0, time.UTC), 0, false},
+		{RotateSize, "foo-20140504.log", time.Date(2014, 5, 4, 0, 0, 0, 0, time.UTC), 0, false},
+		{RotateSize, "foo-201405.log", time.Date(2014, 5, 1, 0, 0, 0, 0, time.UTC), 0, false},
+		{RotateSize, "foo-2014-05-04T14-44-33.123.log", time.Date(2014, 5, 4, 14, 44, 33, 123000000, time.UTC), 0, false},
+		{RotateHourly, "foo-20140504-14.log", time.Date(2014, 5, 4, 14, 0, 0, 0, time.UTC), 0, false},
+		{RotateHourly, "foo-20140504-14-3.log", time.Date(2014, 5, 4, 14, 0, 0, 0, time.UTC), 3, false},
+		{RotateHourly, "foo-20140504144433.log", time.Date(2014, 5, 4, 14, 44, 33, 0, time.UTC), 0, false},
+		{RotateDaily, "foo-20140504-14.log", time.Date(2014, 5, 4, 0, 0, 0, 0, time.UTC), 14, false},
+		{RotateDaily, "foo-20140504-1.log", time.Date(2014, 5, 4, 0, 0, 0, 0, time.UTC), 1, false},
+		{RotateMonthly, "foo-201405-1.log", time.Date(2014, 5, 1, 0, 0, 0, 0, time.UTC), 1, false},
+		{RotateDaily, "foo-20140504-0.log", time.Time{}, 0, true},
+		{RotateDaily, "foo-20140504-01.log", time.Date(2014, 5, 4, 1, 0, 0, 0, time.UTC), 0, false},
+		{RotateDaily, "foo-2014050.log", time.Time{}, 0, true},
+		{RotateDaily, "foo-20141304.log", time.Time{}, 0, true},
+		{RotateDaily, "foo-.log", time.Time{}, 0, true},
+	}
+
+	for _, test := range tests {
+		l := &Roller{filename: "/var/log/myfoo/foo.log", rotateType: test.rotateType}
+		prefix, ext := l.prefixAndExt()
+		got, seq, err := l.parseBackupName(test.filename, prefix, ext)
+		if !got.Equal(test.want) || seq != test.wantSeq || (err != nil) != test.wantErr {
+			t.Errorf("%s %s: got %v %d %v, want %v %d", test.rotateType, test.filename, got, seq, err, test.want, test.wantSeq)
+		}
+	}
+}
+
+func TestTimeRotateHourlyRetention(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	dir := makeTempDir("TestTimeRotateHourlyRetention", t)
+	defer os.RemoveAll(dir)
+
+	// a backup left behind by rotation by size
+	legacy := filepath.Join(dir, "foobar-20261018090000.log")
+	err := ioutil.WriteFile(legacy, []byte("data"), 0644)
+	isNil(err, t)
+
+	filename := logFile(dir)
+	r, err := NewRoller(filename, &Options{
+		MaxBackups: 2,
+		Compress:   true,
+		RotateType: RotateHourly,
+	})
+	isNil(err, t)
+	defer r.Close()
+
+	b := []byte("boo!")
+	for i := 0; i < 3; i++ {
+		n, err := r.Write(b)
+		isNil(err, t)
+		equals(len(b), n, t)
+		newFakeTime(time.Hour)
+	}
+	n, err := r.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+
+	// we need to wait a little bit since the files get compressed on a different
+	// goroutine.
+	<-time.After(300 * time.Millisecond)
+
+	notExist(legacy, t)
+	notExist(filepath.Join(dir, "foobar-20261018-10.log.gz"), t)
+	exists(filepath.Join(dir, "foobar-20261018-11.log.gz"), t)
+	exists(filepath.Join(dir, "foobar-20261018-12.log.gz"), t)
+	existsWithContent(filename, b, t)
+	fileCount(dir, 3, t)
+}
+
 func TestLocalTime(t *testing.T) {
 	currentTime = fakeTime
 
@@ -736,6 +972,34 @@ func TestCompressOnRotate(t *testing.T) {
 	fileCount(dir, 2, t)
 }
 
+func TestCompressRotateTwiceInPeriod(t *testing.T) {
+	currentTime = fakeTime
+
+	dir := makeTempDir("TestCompressRotateTwiceInPeriod", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{Compress: true, RotateType: RotateDaily, LocalTime: true})
+	isNil(err, t)
+	defer l.Close()
+
+	for _, s := range []string{"first", "second"} {
+		_, err = l.Write([]byte(s))
+		isNil(err, t)
+		isNil(l.Rotate(), t)
+
+		// we need to wait a little bit since the files get compressed on a
+		// different goroutine.
+		<-time.After(300 * time.Millisecond)
+	}
+
+	// the plain name is taken by the compressed first backup, so the second
+	// one gets a sequence number instead of overwriting it
+	existsWithContent(dailyBackupFile(dir, fakeTime())+compressSuffix, gzipped([]byte("first"), t), t)
+	existsWithContent(dailyBackupFile(dir, fakeTime(), 1)+compressSuffix, gzipped([]byte("second"), t), t)
+	fileCount(dir, 3, t)
+}
+
 func TestCompressOnResume(t *testing.T) {
 	currentTime = fakeTime
 
@@ -814,13 +1078,23 @@ func logFile(dir string) string {
 
 func backupFile(dir string, isLocal ...bool) string {
 	if len(isLocal) > 0 && isLocal[0] {
-		return filepath.Join(dir, "foobar-"+fakeTime().Format(backupTimeFormat)+".log")
+		return filepath.Join(dir, "foobar-"+fakeTime().Local().Format(backupTimeFormat)+".log")
 	}
 	return filepath.Join(dir, "foobar-"+fakeTime().UTC().Format(backupTimeFormat)+".log")
 }
 
+// dailyBackupFile returns the name of a daily backup of the given day, with an
+// optional sequence number.
+func dailyBackupFile(dir string, day time.Time, seq ...int) string {
+	name := "foobar-" + day.Local().Format("20060102")
+	if len(seq) > 0 {
+		name += "-" + strconv.Itoa(seq[0])
+	}
+	return filepath.Join(dir, name+".log")
+}
+
 func backupFileLocal(dir string) string {
-	return filepath.Join(dir, "foobar-"+fakeTime().Format(backupTimeFormat)+".log")
+	return filepath.Join(dir, "foobar-"+fakeTime().Local().Format(backupTimeFormat)+".log")
 }
 
 // fileCount checks that the number of files in the directory is exp.
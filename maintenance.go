@@ -0,0 +1,331 @@
+package lumberjack
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaintenanceAction is what maintenance does with a backup.
+type MaintenanceAction string
+
+var (
+	// ActionRemove deletes the backup.
+	ActionRemove MaintenanceAction = "remove"
+	// ActionCompress compresses the backup.
+	ActionCompress MaintenanceAction = "compress"
+)
+
+// MaintenanceReason is the setting a maintenance step is taken for.
+type MaintenanceReason string
+
+var (
+	// ReasonCount is for MaxBackups.
+	ReasonCount MaintenanceReason = "count"
+	// ReasonAge is for MaxAge.
+	ReasonAge MaintenanceReason = "age"
+	// ReasonRetention is for the retention tiers.
+	ReasonRetention MaintenanceReason = "retention"
+	// ReasonQuota is for MaxTotalSize.
+	ReasonQuota MaintenanceReason = "quota"
+	// ReasonDiskSpace is for MinFreeSpace.
+	ReasonDiskSpace MaintenanceReason = "disk_space"
+	// ReasonCompress is for Compress.
+	ReasonCompress MaintenanceReason = "compress"
+)
+
+// MaintenanceStep is an action maintenance takes on a backup.
+type MaintenanceStep struct {
+	// Path is the path of the backup.
+	Path string
+	// Action is what is done with it.
+	Action MaintenanceAction
+	// Reason is why.
+	Reason MaintenanceReason
+	// Timestamp is the time of the backup, as encoded in its name.
+	Timestamp time.Time
+	// Size is the size of the backup on disk.
+	Size int64
+}
+
+// MaintenanceResult is the outcome of a maintenance step.
+type MaintenanceResult struct {
+	MaintenanceStep
+	// Err is the error the step failed with, if any.
+	Err error
+}
+
+// PlanMaintenance returns the steps the compression and removal of old log
+// files would take now, without taking them. Removals for MaxTotalSize and
+// MinFreeSpace are planned from the current sizes of the backups, running the
+// plan may remove fewer as compression shrinks them.
+func (r *Roller) PlanMaintenance() ([]MaintenanceStep, error) {
+	if !r.maintained() {
+		return nil, nil
+	}
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
+
+	files, err := r.oldLogFiles()
+	if err != nil {
+		return nil, err
+	}
+	steps, files, pinned := r.planRetention(files)
+	return append(steps, r.planSpace(files, pinned)...), nil
+}
+
+// RunMaintenance compresses and removes old log files according to the
+// configuration, as the mill does after rotating, and returns the steps it
+// took along with their errors. It stops early if ctx is done, returning
+// ctx.Err().
+func (r *Roller) RunMaintenance(ctx context.Context) ([]MaintenanceResult, error) {
+	if !r.maintained() {
+		return nil, nil
+	}
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
+	return r.runMaintenance(ctx)
+}
+
+// maintained reports whether there is any maintenance to do.
+func (r *Roller) maintained() bool {
+	return r.maxBackups > 0 || r.maxAge > 0 || r.compress || r.maxTotalSize > 0 || r.minFreeSpace > 0 ||
+		len(r.retention) > 0
+}
+
+// runMaintenance is RunMaintenance with millMu held.
+func (r *Roller) runMaintenance(ctx context.Context) ([]MaintenanceResult, error) {
+	files, err := r.oldLogFiles()
+	if err != nil {
+		return nil, err
+	}
+	steps, files, pinned := r.planRetention(files)
+	results, err := r.runSteps(ctx, steps)
+	if err != nil {
+		return results, err
+	}
+	if r.maxTotalSize == 0 && r.minFreeSpace == 0 {
+		return results, nil
+	}
+	// the space is planned after compression, which changes the sizes
+	for _, step := range steps {
+		if step.Action == ActionCompress {
+			if files, err = r.oldLogFiles(); err != nil {
+				return results, err
+			}
+			files, pinned = splitPinned(files)
+			break
+		}
+	}
+	more, err := r.runSteps(ctx, r.planSpace(files, pinned))
+	return append(results, more...), err
+}
+
+// runSteps takes the given steps, stopping early if ctx is done. Removals are
+// taken first, then the backups are compressed by the compression workers.
+func (r *Roller) runSteps(ctx context.Context, steps []MaintenanceStep) ([]MaintenanceResult, error) {
+	var results []MaintenanceResult
+	var compress []MaintenanceStep
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		if step.Action == ActionCompress {
+			compress = append(compress, step)
+			continue
+		}
+		if step.Reason == ReasonDiskSpace {
+			// only as long as it is still needed
+			if free, err := diskFree(r.backupDir()); err != nil || free >= r.minFreeSpace {
+				continue
+			}
+		}
+		err := r.removeBackup(step.Path)
+		if err == nil {
+			err = r.recordRemove(step.Path)
+		}
+		results = append(results, MaintenanceResult{step, err})
+	}
+	compressed, err := r.compressAll(ctx, compress)
+	return append(results, compressed...), err
+}
+
+// compressAll compresses the backups of the given steps with up to
+// compressWorkers workers at a time, stopping early if ctx is done. Results
+// are returned in the order of the steps.
+func (r *Roller) compressAll(ctx context.Context, steps []MaintenanceStep) ([]MaintenanceResult, error) {
+	workers := r.compressWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(steps) {
+		workers = len(steps)
+	}
+	results := make([]MaintenanceResult, len(steps))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r.compressNice != 0 {
+				// best effort, the workers run anyway
+				_ = lowerPriority(r.compressNice)
+			}
+			for i := range next {
+				src := steps[i].Path
+				dst := src + r.compressor.Suffix()
+				err := compressLogFile(ctx, src, dst, r.compressor, r.throttle)
+				if err == nil {
+					err = r.recordCompress(src, dst)
+				}
+				results[i] = MaintenanceResult{steps[i], err}
+			}
+		}()
+	}
+	var err error
+	sent := 0
+loop:
+	for sent < len(steps) {
+		select {
+		case next <- sent:
+			sent++
+		case <-ctx.Done():
+			err = ctx.Err()
+			break loop
+		}
+	}
+	close(next)
+	wg.Wait()
+	return results[:sent], err
+}
+
+// planRetention plans the removal of backups for MaxBackups, MaxAge and the
+// retention tiers, and the compression of those that remain. files are
+// sorted newest first, the backups that remain are returned in that order,
+// apart from the pinned ones, which always remain.
+func (r *Roller) planRetention(files []logInfo) (steps []MaintenanceStep, remaining, pinned []logInfo) {
+	files, pinned = splitPinned(files)
+	remove := func(f logInfo, reason MaintenanceReason) {
+		steps = append(steps, newStep(f, ActionRemove, reason))
+	}
+
+	if r.maxBackups > 0 && r.maxBackups < len(files) {
+		preserved := make(map[string]bool)
+		var remaining []logInfo
+		for _, f := range files {
+			// Only count the uncompressed log file or the
+			// compressed log file, not both.
+			preserved[trimCompressed(f.path)] = true
+
+			if len(preserved) > r.maxBackups {
+				remove(f, ReasonCount)
+			} else {
+				remaining = append(remaining, f)
+			}
+		}
+		files = remaining
+	}
+	if r.maxAge > 0 {
+		cutoff := currentTime().Add(-1 * r.maxAge)
+
+		var remaining []logInfo
+		for _, f := range files {
+			if f.timestamp.Before(cutoff) {
+				remove(f, ReasonAge)
+			} else {
+				remaining = append(remaining, f)
+			}
+		}
+		files = remaining
+	}
+	if len(r.retention) > 0 {
+		var dropped []logInfo
+		files, dropped = r.retain(files, currentTime())
+		for _, f := range dropped {
+			remove(f, ReasonRetention)
+		}
+	}
+
+	if r.compress {
+		all := append(append([]logInfo(nil), files...), pinned...)
+		if r.numberedBackups {
+			sort.Sort(byIndex(all))
+		} else {
+			sort.Sort(byFormatTime(all))
+		}
+		cutoff := currentTime().Add(-1 * r.compressAfterAge)
+		newest := make(map[string]bool)
+		for _, f := range all {
+			// the newest backups are left for plain tools to read
+			name := trimCompressed(f.path)
+			if len(newest) < r.compressAfter || newest[name] {
+				newest[name] = true
+				continue
+			}
+			if r.compressAfterAge > 0 && f.timestamp.After(cutoff) {
+				continue
+			}
+			if !isCompressed(f.Name()) {
+				steps = append(steps, newStep(f, ActionCompress, ReasonCompress))
+			}
+		}
+	}
+	return steps, files, pinned
+}
+
+// planSpace plans the removal of the oldest backups until they, and the
+// current log file if it counts, take up at most MaxTotalSize bytes, and
+// until the backup directory's filesystem has MinFreeSpace bytes free. files
+// are sorted newest first. The pinned backups are never removed, but count
+// towards MaxTotalSize.
+func (r *Roller) planSpace(files, pinned []logInfo) []MaintenanceStep {
+	var steps []MaintenanceStep
+	if r.maxTotalSize > 0 {
+		var total int64
+		if r.totalSizeWithActive {
+			if info, err := osStat(r.newFilename()); err == nil {
+				total = info.Size()
+			}
+		}
+		for _, f := range pinned {
+			total += f.Size()
+		}
+		var remaining []logInfo
+		for _, f := range files {
+			total += f.Size()
+			if total <= r.maxTotalSize {
+				remaining = append(remaining, f)
+			} else {
+				steps = append(steps, newStep(f, ActionRemove, ReasonQuota))
+			}
+		}
+		files = remaining
+	}
+	if r.minFreeSpace > 0 {
+		free, err := diskFree(r.backupDir())
+		for i := len(files) - 1; i >= 0 && err == nil && free < r.minFreeSpace; i-- {
+			steps = append(steps, newStep(files[i], ActionRemove, ReasonDiskSpace))
+			free += files[i].Size()
+		}
+	}
+	return steps
+}
+
+func newStep(f logInfo, action MaintenanceAction, reason MaintenanceReason) MaintenanceStep {
+	return MaintenanceStep{Path: f.path, Action: action, Reason: reason, Timestamp: f.timestamp, Size: f.Size()}
+}
+
+// removeBackup removes a backup, and the directories it leaves empty.
+func (r *Roller) removeBackup(path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	if r.nested() {
+		r.removeEmptyDirs(filepath.Dir(path))
+	}
+	return nil
+}
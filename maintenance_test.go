@@ -0,0 +1,66 @@
+package lumberjack
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlanAndRunMaintenance(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	dir := makeTempDir("TestPlanAndRunMaintenance", t)
+	defer os.RemoveAll(dir)
+
+	// five backups, one a day
+	var names []string
+	for d := 1; d <= 5; d++ {
+		ts := fakeCurrentTime.Add(-time.Duration(d) * 24 * time.Hour)
+		name := filepath.Join(dir, fmt.Sprintf("foobar-%s.log", ts.Format(backupTimeFormat)))
+		isNil(ioutil.WriteFile(name, []byte("boo!"), 0644), t)
+		names = append(names, name)
+	}
+
+	l := &Roller{filename: logFile(dir), maxBackups: 4, maxAge: 84 * time.Hour, maxTotalSize: 4}
+	l.policy = SizePolicy{10}
+
+	steps, err := l.PlanMaintenance()
+	isNil(err, t)
+	equals(4, len(steps), t)
+	equals(MaintenanceStep{names[4], ActionRemove, ReasonCount, fakeCurrentTime.Add(-120 * time.Hour), 4}, steps[0], t)
+	equals(MaintenanceStep{names[3], ActionRemove, ReasonAge, fakeCurrentTime.Add(-96 * time.Hour), 4}, steps[1], t)
+	equals(names[1], steps[2].Path, t)
+	equals(ReasonQuota, steps[2].Reason, t)
+	equals(names[2], steps[3].Path, t)
+	equals(ReasonQuota, steps[3].Reason, t)
+
+	// planning doesn't touch anything
+	fileCount(dir, 5, t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := l.RunMaintenance(ctx)
+	equals(context.Canceled, err, t)
+	equals(0, len(results), t)
+
+	results, err = l.RunMaintenance(context.Background())
+	isNil(err, t)
+	equals(4, len(results), t)
+	for i, res := range results {
+		isNil(res.Err, t)
+		equals(steps[i], res.MaintenanceStep, t)
+	}
+	exists(names[0], t)
+	fileCount(dir, 1, t)
+
+	// a file that can't be removed is reported
+	isNil(os.Remove(names[0]), t)
+	res, err := l.runSteps(context.Background(), []MaintenanceStep{{Path: names[0], Action: ActionRemove}})
+	isNil(err, t)
+	equals(1, len(res), t)
+	notNil(res[0].Err, t)
+}
@@ -0,0 +1,302 @@
+package lumberjack
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// manifestSuffix is appended to the log file name for the name of the
+// manifest, e.g. foo.log.manifest.
+const manifestSuffix = ".manifest"
+
+// The operations recorded in the manifest.
+const (
+	opRotate   = "rotate"
+	opCompress = "compress"
+	opRename   = "rename"
+	opRemove   = "remove"
+)
+
+// manifestEntry is a line of the manifest. The manifest is only ever appended
+// to, the state of the backups is the result of replaying it.
+type manifestEntry struct {
+	Op string `json:"op"`
+	// Name is the path of the backup relative to the log file's directory,
+	// using slashes.
+	Name string `json:"name"`
+	// From is the name of the backup that was compressed or renamed.
+	From string `json:"from,omitempty"`
+	Size int64  `json:"size,omitempty"`
+	// Start and End are the time range the backup covers, from when the log
+	// file was opened to when it was rotated.
+	Start  *time.Time `json:"start,omitempty"`
+	End    *time.Time `json:"end,omitempty"`
+	SHA256 string     `json:"sha256,omitempty"`
+	// Time is when the entry was recorded.
+	Time time.Time `json:"time"`
+}
+
+// VerifyProblem is what is wrong with a backup found by Verify.
+type VerifyProblem string
+
+var (
+	// ProblemMissing is a backup in the manifest which doesn't exist.
+	ProblemMissing VerifyProblem = "missing"
+	// ProblemSize is a backup whose size differs from the manifest.
+	ProblemSize VerifyProblem = "size"
+	// ProblemChecksum is a backup whose SHA-256 differs from the manifest.
+	ProblemChecksum VerifyProblem = "checksum"
+	// ProblemUnrecorded is a backup which isn't in the manifest.
+	ProblemUnrecorded VerifyProblem = "unrecorded"
+)
+
+// VerifyMismatch is a backup which doesn't match the manifest.
+type VerifyMismatch struct {
+	// Path is the path of the backup.
+	Path    string
+	Problem VerifyProblem
+	// Expected and Actual are the size or SHA-256 of the backup, according
+	// to the manifest and on disk.
+	Expected, Actual string
+}
+
+// Verify checks the backups against the manifest kept with Options.Manifest,
+// re-hashing each of them. It returns the backups which are missing, have
+// been altered or aren't in the manifest, an empty list meaning all is well.
+func (r *Roller) Verify() ([]VerifyMismatch, error) {
+	if !r.manifest {
+		return nil, errors.New("the roller keeps no manifest")
+	}
+	// don't race with the mill changing the backups
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
+
+	entries, err := r.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var mismatches []VerifyMismatch
+	for _, name := range names {
+		e := entries[name]
+		path := r.manifestPath(name)
+		info, err := os.Stat(path)
+		if err != nil {
+			mismatches = append(mismatches, VerifyMismatch{Path: path, Problem: ProblemMissing})
+			continue
+		}
+		if info.Size() != e.Size {
+			mismatches = append(mismatches, VerifyMismatch{path, ProblemSize, fmt.Sprint(e.Size), fmt.Sprint(info.Size())})
+			continue
+		}
+		sum, err := hashFile(path)
+		if err != nil {
+			return mismatches, err
+		}
+		if sum != e.SHA256 {
+			mismatches = append(mismatches, VerifyMismatch{path, ProblemChecksum, e.SHA256, sum})
+		}
+	}
+
+	files, err := r.oldLogFiles()
+	if err != nil {
+		return mismatches, err
+	}
+	for _, f := range files {
+		if _, ok := entries[r.manifestName(f.path)]; !ok {
+			mismatches = append(mismatches, VerifyMismatch{Path: f.path, Problem: ProblemUnrecorded})
+		}
+	}
+	return mismatches, nil
+}
+
+// manifestFile returns the path of the manifest.
+func (r *Roller) manifestFile() string {
+	return r.newFilename() + manifestSuffix
+}
+
+// manifestName returns the name of the backup at path in the manifest.
+func (r *Roller) manifestName(path string) string {
+	if rel, err := filepath.Rel(r.dir(), path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+// manifestPath returns the path of the backup with the given name in the
+// manifest.
+func (r *Roller) manifestPath(name string) string {
+	path := filepath.FromSlash(name)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(r.dir(), path)
+}
+
+// record appends e to the manifest, if the roller keeps one. The manifest is
+// synced, so that the entry lasts through a crash.
+func (r *Roller) record(e manifestEntry) error {
+	if !r.manifest {
+		return nil
+	}
+	e.Time = currentTime()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	r.manifestMu.Lock()
+	defer r.manifestMu.Unlock()
+	f, err := os.OpenFile(r.manifestFile(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("can't open manifest: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("can't write manifest: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// recordRotate records the rotation of the log file to the backup at path.
+// sum is the SHA-256 of the log file's contents if known, otherwise the
+// backup is hashed.
+func (r *Roller) recordRotate(path string, sum hash.Hash) error {
+	if !r.manifest {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	var digest string
+	if sum != nil {
+		digest = hex.EncodeToString(sum.Sum(nil))
+	} else if digest, err = hashFile(path); err != nil {
+		return err
+	}
+	e := manifestEntry{Op: opRotate, Name: r.manifestName(path), Size: info.Size(), SHA256: digest}
+	// the log file rotated right as it is opened has no known start
+	if !r.opened.IsZero() {
+		start := r.opened
+		e.Start = &start
+	}
+	end := currentTime()
+	e.End = &end
+	return r.record(e)
+}
+
+// recordCompress records the compression of the backup src to dst.
+func (r *Roller) recordCompress(src, dst string) error {
+	if !r.manifest {
+		return nil
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		return err
+	}
+	digest, err := hashFile(dst)
+	if err != nil {
+		return err
+	}
+	return r.record(manifestEntry{
+		Op:     opCompress,
+		Name:   r.manifestName(dst),
+		From:   r.manifestName(src),
+		Size:   info.Size(),
+		SHA256: digest,
+	})
+}
+
+// recordRename records the renaming of the backup oldpath to newpath.
+func (r *Roller) recordRename(oldpath, newpath string) error {
+	return r.record(manifestEntry{Op: opRename, Name: r.manifestName(newpath), From: r.manifestName(oldpath)})
+}
+
+// recordRemove records the removal of the backup at path.
+func (r *Roller) recordRemove(path string) error {
+	return r.record(manifestEntry{Op: opRemove, Name: r.manifestName(path)})
+}
+
+// loadManifest replays the manifest, returning the entries of the backups
+// which should exist by their name.
+func (r *Roller) loadManifest() (map[string]manifestEntry, error) {
+	entries := make(map[string]manifestEntry)
+	f, err := os.Open(r.manifestFile())
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e manifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid manifest line %d: %w", line, err)
+		}
+		switch e.Op {
+		case opRotate:
+			entries[e.Name] = e
+		case opCompress:
+			from := entries[e.From]
+			delete(entries, e.From)
+			e.Start, e.End = from.Start, from.End
+			entries[e.Name] = e
+		case opRename:
+			from, ok := entries[e.From]
+			delete(entries, e.From)
+			if ok {
+				from.Name = e.Name
+				entries[e.Name] = from
+			}
+		case opRemove:
+			delete(entries, e.Name)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// hashFile returns the hex encoded SHA-256 of the file at path.
+func hashFile(path string) (string, error) {
+	h, err := hashContents(path)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashContents returns a SHA-256 hash which has been fed the contents of the
+// file at path.
+func hashContents(path string) (hash.Hash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
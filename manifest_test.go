@@ -0,0 +1,108 @@
+package lumberjack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManifest(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestManifest", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	isNil(ioutil.WriteFile(filename, []byte("old"), 0644), t)
+	l, err := NewRoller(filename, &Options{MaxBackups: 2, Compress: true, CompressAfter: 1, MaxSize: 100, Manifest: true})
+	isNil(err, t)
+	defer l.Close()
+
+	_, err = l.Verify()
+	isNil(err, t)
+
+	var backups []string
+	for _, s := range []string{"boo!", "foooooo!", "baaar!"} {
+		_, err = l.Write([]byte(s))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+		backups = append(backups, backupFile(dir))
+		// we need to wait a little bit since the files get compressed on a
+		// different goroutine.
+		<-time.After(300 * time.Millisecond)
+	}
+	notExist(backups[0]+compressSuffix, t)
+	exists(backups[1]+compressSuffix, t)
+	existsWithContent(backups[2], []byte("baaar!"), t)
+
+	entries, err := l.loadManifest()
+	isNil(err, t)
+	equals(2, len(entries), t)
+	e := entries[filepath.Base(backups[2])]
+	equals(int64(6), e.Size, t)
+	equals("6102682be343d3e5b14e9adc44bbb2a69130695fbdd1b85a9be7340533fe0c8c", e.SHA256, t)
+	notNil(e.Start, t)
+	notNil(e.End, t)
+	e = entries[filepath.Base(backups[1])+compressSuffix]
+	equals(filepath.Base(backups[1]), e.From, t)
+	notNil(e.Start, t)
+
+	mismatches, err := l.Verify()
+	isNil(err, t)
+	equals(0, len(mismatches), t)
+
+	isNil(ioutil.WriteFile(backups[2], []byte("BAAAR!"), 0644), t)
+	isNil(os.Remove(backups[1]+compressSuffix), t)
+	stray := filepath.Join(dir, "foobar-19990101000000.log")
+	isNil(ioutil.WriteFile(stray, []byte("stray"), 0644), t)
+
+	mismatches, err = l.Verify()
+	isNil(err, t)
+	equals(3, len(mismatches), t)
+	equals(VerifyMismatch{Path: backups[1] + compressSuffix, Problem: ProblemMissing}, mismatches[0], t)
+	equals(backups[2], mismatches[1].Path, t)
+	equals(ProblemChecksum, mismatches[1].Problem, t)
+	equals(VerifyMismatch{Path: stray, Problem: ProblemUnrecorded}, mismatches[2], t)
+}
+
+func TestManifestNumberedBackups(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestManifestNumberedBackups", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{NumberedBackups: true, MaxSize: 100, Manifest: true})
+	isNil(err, t)
+	defer l.Close()
+
+	for _, s := range []string{"one", "two", "three"} {
+		_, err = l.Write([]byte(s))
+		isNil(err, t)
+		isNil(l.Rotate(), t)
+	}
+	existsWithContent(filename+".1", []byte("three"), t)
+	existsWithContent(filename+".3", []byte("one"), t)
+
+	entries, err := l.loadManifest()
+	isNil(err, t)
+	equals(3, len(entries), t)
+	equals(int64(3), entries["foobar.log.3"].Size, t)
+
+	mismatches, err := l.Verify()
+	isNil(err, t)
+	equals(0, len(mismatches), t)
+
+	// a backup which changed size isn't re-hashed
+	isNil(ioutil.WriteFile(filename+".2", []byte("twoo"), 0644), t)
+	mismatches, err = l.Verify()
+	isNil(err, t)
+	equals([]VerifyMismatch{{filename + ".2", ProblemSize, "3", "4"}}, mismatches, t)
+
+	l2, err := NewRoller(logFile(dir), &Options{})
+	isNil(err, t)
+	defer l2.Close()
+	_, err = l2.Verify()
+	notNil(err, t)
+}
@@ -0,0 +1,17 @@
+//go:build linux
+// +build linux
+
+package lumberjack
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// lowerPriority sets the nice value of the calling goroutine's thread. The
+// goroutine is locked to the thread, which ends with it, so the priority
+// doesn't leak to other goroutines.
+func lowerPriority(nice int) error {
+	runtime.LockOSThread()
+	return syscall.Setpriority(syscall.PRIO_PROCESS, syscall.Gettid(), nice)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package lumberjack
+
+// lowerPriority is only supported on linux.
+func lowerPriority(_ int) error {
+	return nil
+}
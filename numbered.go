@@ -0,0 +1,132 @@
+package lumberjack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// numberedName returns the name of the numbered backup of name with the given
+// index, e.g. foo.log.2.
+func numberedName(name string, index int) string {
+	return name + "." + strconv.Itoa(index)
+}
+
+// numberedLogFiles is oldLogFiles for numbered backups. The backups are sorted
+// by index, newest first, and stamped with their modification time.
+func (r *Roller) numberedLogFiles() ([]logInfo, error) {
+	files, err := ioutil.ReadDir(r.backupDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read log file directory: %s", err)
+	}
+	logFiles := []logInfo{}
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		seq, ok := r.numberedIndex(f.Name())
+		if !ok {
+			continue
+		}
+		logFiles = append(logFiles, logInfo{f.ModTime(), seq, filepath.Join(r.backupDir(), f.Name()), f})
+	}
+
+	sort.Sort(byIndex(logFiles))
+
+	return logFiles, nil
+}
+
+// numberedIndex returns the index of the numbered backup with the given name,
+// compressed or not, or false if it isn't one.
+func (r *Roller) numberedIndex(name string) (int, bool) {
+	prefix := filepath.Base(r.newFilename()) + "."
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+	index := trimCompressed(name[len(prefix):])
+	seq, err := strconv.Atoi(index)
+	if err != nil || seq <= 0 || index != strconv.Itoa(seq) {
+		return 0, false
+	}
+	return seq, true
+}
+
+// shiftBackups renames each numbered backup foo.log.N, and foo.log.N.gz, to
+// foo.log.N+1, starting from the highest, so that foo.log.1 is free for the
+// next backup. As no file is ever overwritten, an interrupted shift leaves at
+// most a gap in the numbers, which recoverBackups closes. The caller holds
+// millMu.
+func (r *Roller) shiftBackups() error {
+	files, err := r.numberedLogFiles()
+	if err != nil {
+		return err
+	}
+	name := r.archivedName()
+	for i := len(files) - 1; i >= 0; i-- {
+		f := files[i]
+		newname := numberedName(name, f.seq+1) + compressedSuffix(f.Name())
+		if err := r.renameBackup(f.path, newname); err != nil {
+			return fmt.Errorf("can't shift backup: %w", err)
+		}
+	}
+	return nil
+}
+
+// recoverBackups closes the gaps in numbered backups left by a shift which
+// was interrupted by a crash. It runs after recoverCompression, so each index
+// has a single backup.
+func (r *Roller) recoverBackups() error {
+	files, err := r.numberedLogFiles()
+	if err != nil {
+		return err
+	}
+	name := r.archivedName()
+	index := 1
+	for _, f := range files {
+		if f.seq != index {
+			newname := numberedName(name, index) + compressedSuffix(f.Name())
+			if err := r.renameBackup(f.path, newname); err != nil {
+				return fmt.Errorf("can't renumber backup: %w", err)
+			}
+		}
+		index++
+	}
+	return nil
+}
+
+// renameBackup renames a numbered backup along with the marker pinning it, if
+// any, and records the rename in the manifest.
+func (r *Roller) renameBackup(oldpath, newpath string) error {
+	if pinned(oldpath) {
+		if err := os.Rename(pinPath(oldpath), pinPath(newpath)); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	return r.recordRename(oldpath, newpath)
+}
+
+// byIndex sorts numbered backups by index, lowest first.
+type byIndex []logInfo
+
+func (b byIndex) Less(i, j int) bool {
+	return b[i].seq < b[j].seq
+}
+
+func (b byIndex) Swap(i, j int) {
+	b[i], b[j] = b[j], b[i]
+}
+
+func (b byIndex) Len() int {
+	return len(b)
+}
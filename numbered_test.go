@@ -0,0 +1,104 @@
+package lumberjack
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNumberedBackups(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestNumberedBackups", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{NumberedBackups: true, MaxBackups: 2, MaxSize: 10})
+	isNil(err, t)
+	defer l.Close()
+
+	for _, s := range []string{"one", "two", "three", "four"} {
+		_, err = l.Write([]byte(s))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+	}
+
+	// we need to wait a little bit since the files get removed on a different
+	// goroutine.
+	<-time.After(10 * time.Millisecond)
+
+	// the highest index is dropped first
+	existsWithContent(filename, []byte{}, t)
+	existsWithContent(filename+".1", []byte("four"), t)
+	existsWithContent(filename+".2", []byte("three"), t)
+	notExist(filename+".3", t)
+	fileCount(dir, 3, t)
+}
+
+func TestNumberedBackupsCompress(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestNumberedBackupsCompress", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{NumberedBackups: true, Compress: true, MaxSize: 10})
+	isNil(err, t)
+	defer l.Close()
+
+	for _, s := range []string{"one", "two"} {
+		_, err = l.Write([]byte(s))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+
+		// we need to wait a little bit since the files get compressed on a
+		// different goroutine.
+		<-time.After(300 * time.Millisecond)
+	}
+
+	// the compressed backup was shifted along with the others
+	existsWithContent(filename+".1"+compressSuffix, gzipped([]byte("two"), t), t)
+	existsWithContent(filename+".2"+compressSuffix, gzipped([]byte("one"), t), t)
+	notExist(filename+".1", t)
+	fileCount(dir, 3, t)
+}
+
+func TestNumberedBackupsRecover(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestNumberedBackupsRecover", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+
+	// an interrupted shift left foo.log.1 free, and an interrupted compression
+	// left a partial foo.log.3.gz beside foo.log.3
+	isNil(ioutil.WriteFile(filename+".2", []byte("two"), 0644), t)
+	isNil(ioutil.WriteFile(filename+".3", []byte("three"), 0644), t)
+	isNil(ioutil.WriteFile(filename+".3"+compressSuffix, []byte{}, 0644), t)
+	isNil(ioutil.WriteFile(filename+".5"+compressSuffix, []byte("five"), 0644), t)
+
+	l, err := NewRoller(filename, &Options{NumberedBackups: true, MaxSize: 10})
+	isNil(err, t)
+	defer l.Close()
+
+	existsWithContent(filename+".1", []byte("two"), t)
+	existsWithContent(filename+".2", []byte("three"), t)
+	existsWithContent(filename+".3"+compressSuffix, []byte("five"), t)
+	notExist(filename+".4", t)
+	notExist(filename+".5"+compressSuffix, t)
+
+	_, err = NewRoller(filename, &Options{NumberedBackups: true, BackupNameTemplate: "{prefix}-{time}{ext}"})
+	notNil(err, t)
+}
+
+func gzipped(b []byte, t testing.TB) []byte {
+	bc := new(bytes.Buffer)
+	gz := gzip.NewWriter(bc)
+	_, err := gz.Write(b)
+	isNil(err, t)
+	isNil(gz.Close(), t)
+	return bc.Bytes()
+}
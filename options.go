@@ -4,11 +4,18 @@ import "time"
 
 type Options struct {
 	// MaxSize is the maximum size in megabytes of the log file before it gets rotated. It defaults to 100 megabytes.
-	// optional, only used when RotateType is RotateSize or not set
+	// optional, only used for rotation when RotateType is RotateSize or not set, or when EnforceMaxSize is true,
+	// a single write longer than it is handled by OversizedWrite with any RotateType
 	MaxSize int64 `json:"maxsize" yaml:"maxsize"`
+	// OversizedWrite: optional: OversizedReject, OversizedSplit, OversizedTruncate, OversizedOverflowFile, default OversizedReject
+	OversizedWrite OversizedWrite `json:"oversized_write" yaml:"oversized_write"`
+	// OversizedPath is the file written to with OversizedOverflowFile.
+	OversizedPath string `json:"oversized_path" yaml:"oversized_path"`
 	// MaxAge is the maximum time to retain old log files based on the timestamp
 	// encoded in their filename. The default is not to remove old log files
-	// based on age.
+	// based on age. If RotateType is time based, a MaxAge below one second is
+	// a number of rotate periods, e.g. 28 with RotateDaily keeps 28 days. With
+	// RotateCron it must be a duration of at least a second.
 	MaxAge time.Duration `json:"maxage" yaml:"maxage"`
 
 	// MaxBackups is the maximum number of old log files to retain. The default
@@ -16,16 +23,82 @@ type Options struct {
 	// deleted.)
 	MaxBackups int `json:"maxbackups" yaml:"maxbackups"`
 
+	// Retention are tiers of retention by age, e.g. every backup for a day,
+	// one per day for a month and one per month for a year, see RetentionTier.
+	// Backups older than all tiers are deleted. MaxBackups, MaxAge and
+	// MaxTotalSize still apply. The default is to have no tiers.
+	Retention []RetentionTier `json:"retention" yaml:"retention"`
+
+	// MaxTotalSize is the maximum size in bytes all old log files may take up
+	// together, as stored on disk, i.e. compressed if they are. The oldest are
+	// deleted until they fit. The default is not to limit their total size.
+	MaxTotalSize int64 `json:"max_total_size" yaml:"max_total_size"`
+	// MaxTotalSizeIncludesActive counts the current log file towards
+	// MaxTotalSize as well.
+	MaxTotalSizeIncludesActive bool `json:"max_total_size_includes_active" yaml:"max_total_size_includes_active"`
+
+	// MinFreeSpace is the free space in bytes the filesystems of the log file
+	// and its backups should keep. Below it on either the oldest backups are
+	// deleted, regardless of the other retention settings, until the backups'
+	// filesystem has it again. With an ArchiveDir on another filesystem,
+	// deleting backups can't free the log file's, which is then only guarded
+	// by CriticalFreeSpace. Free space is only checked on linux. The default
+	// is not to check it.
+	MinFreeSpace int64 `json:"min_free_space" yaml:"min_free_space"`
+	// CriticalFreeSpace is the free space in bytes below which, on the
+	// filesystem of the log file or of its backups, writes are handled by
+	// DiskFullMode instead of going to the log file.
+	CriticalFreeSpace int64 `json:"critical_free_space" yaml:"critical_free_space"`
+	// DiskFullMode: optional: DiskFullDrop, DiskFullBlock, DiskFullFallback, default DiskFullDrop
+	DiskFullMode DiskFullMode `json:"disk_full_mode" yaml:"disk_full_mode"`
+	// FallbackPath is the file written to with DiskFullFallback, preferably on
+	// another filesystem.
+	FallbackPath string `json:"fallback_path" yaml:"fallback_path"`
+
 	// LocalTime determines if the time used for formatting the timestamps in
 	// backup files is the computer's local time. The default is to use UTC
 	// time.
 	LocalTime bool `json:"localtime" yaml:"localtime"`
 
 	// Compress determines if the rotated log files should be compressed
-	// using gzip. The default is not to perform compression.
+	// using gzip, or Compressor if set. The default is not to perform
+	// compression.
 	Compress bool `json:"compress" yaml:"compress"`
+	// CompressLevel is the gzip compression level, from gzip.BestSpeed to
+	// gzip.BestCompression. The default is gzip.DefaultCompression.
+	CompressLevel int `json:"compress_level" yaml:"compress_level"`
+	// Compressor compresses the rotated log files instead of gzip, e.g.
+	// Bzip2Compressor for smaller backups. Backups compressed with gzip or
+	// any compressor registered by RegisterCompressor are still recognized,
+	// so switching compressors doesn't leave old backups behind.
+	Compressor Compressor `json:"-" yaml:"-"`
+	// CompressAfter leaves this many of the newest backups uncompressed, so
+	// that they can be read with plain tools. The default is to compress all.
+	CompressAfter int `json:"compress_after" yaml:"compress_after"`
+	// CompressAfterAge leaves backups younger than this, according to the
+	// timestamp in their name, uncompressed. The default is to compress all.
+	CompressAfterAge time.Duration `json:"compress_after_age" yaml:"compress_after_age"`
+	// CompressWorkers is how many backups are compressed at once, so that a
+	// backlog of backups, e.g. after an outage, is caught up with quickly. The
+	// default is 1.
+	CompressWorkers int `json:"compress_workers" yaml:"compress_workers"`
+	// CompressRateLimit is the most bytes per second read by all compression
+	// workers together, so that they don't starve the application of IO. The
+	// default is not to limit it.
+	CompressRateLimit int64 `json:"compress_rate_limit" yaml:"compress_rate_limit"`
+	// CompressNice is the nice value the compression workers run at, from
+	// -20 to 19, higher values yielding the CPU to the application. It is only
+	// supported on linux, and raising the priority needs privileges. The
+	// default is to run at the priority of the process.
+	CompressNice int `json:"compress_nice" yaml:"compress_nice"`
 
-	// RotateType: optional:  RotateHourly, RotateDaily, RotateSize, default RotateSize
+	// Manifest records the name, size, time range and SHA-256 of each backup
+	// in foo.log.manifest next to the log file, as it is rotated and
+	// compressed, so that Verify can tell if backups were altered or lost.
+	// The default is to keep no manifest.
+	Manifest bool `json:"manifest" yaml:"manifest"`
+
+	// RotateType: optional:  RotateMinute, RotateHourly, RotateDaily, RotateWeekly, RotateMonthly, RotateCron, RotateSize, default RotateSize
 	RotateType RotateType `json:"rotate_type" yaml:"rotate_type"`
 	// if RotateType is RotateHourly, need make 24/RotateTime > 0
 	// if RotateType is not empty, default RotateTime is 1
@@ -33,7 +106,121 @@ type Options struct {
 	//  RotateType is RotateDaily, RotateTime is 2, means rotate log file every 2 days,
 	// rotate at xxxx-xx-01 00:00,  xxxx-xx-03 00:00
 	// or rotate at xxxx-xx-02 00:00,  xxxx-xx-04 00:00
+	// RotateType is RotateWeekly, RotateTime is 2, means rotate log file every 2 weeks on WeekStart
+	// RotateType is RotateMonthly, RotateTime is 3, means rotate log file every 3 months on the 1st
 	RotateTime uint `json:"rotate_time" yaml:"rotate_time"`
 
+	// WeekStart is the day a week begins on when RotateType is RotateWeekly. It
+	// defaults to Sunday; use time.Monday for ISO weeks. Weekly backups are
+	// named after the first day of their week.
+	WeekStart time.Weekday `json:"week_start" yaml:"week_start"`
+
+	// RotateCron is the schedule used when RotateType is RotateCron, as a
+	// standard 5-field cron expression "minute hour day-of-month month
+	// day-of-week", e.g. "30 2,14 * * *" rotates at 02:30 and 14:30 and
+	// "0 0 * * mon-fri" every weekday at midnight. It is evaluated in local time
+	// if LocalTime is true and in UTC otherwise.
+	RotateCron string `json:"rotate_cron" yaml:"rotate_cron"`
+
+	// ScheduledRotate rotates the log file by a background timer at the end of
+	// each time period, even if nothing is written, so that finished periods
+	// don't linger in the current log file. The timer is stopped by Close.
+	ScheduledRotate bool `json:"scheduled_rotate" yaml:"scheduled_rotate"`
+
+	// SkipEmptyRotate makes scheduled rotations keep an empty log file for the
+	// next period instead of moving it aside as an empty backup.
+	SkipEmptyRotate bool `json:"skip_empty_rotate" yaml:"skip_empty_rotate"`
+
+	// Async makes Write copy the data to an in-memory buffer and return, for a
+	// background goroutine to write it to the log file, taking file I/O off the
+	// caller's path. Rotation is decided as the buffer is written out. Sync and
+	// Close write out everything written before them. An error writing the
+	// buffer is returned by the next Write, Sync or Close.
+	Async bool `json:"async" yaml:"async"`
+	// BufferSize is the size in bytes of the buffer of Async. While it is
+	// full, writes are handled by OverflowPolicy. Writes larger than it are
+	// written directly with OverflowBlock, and dropped with the other
+	// policies. The default is 256 KiB.
+	BufferSize int `json:"buffer_size" yaml:"buffer_size"`
+	// FlushInterval is how often the buffer of Async is written to the log
+	// file, or sooner when it is half full. The default is one second.
+	FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval"`
+	// OverflowPolicy: optional: OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowSample, default OverflowBlock
+	// Dropped records are counted by Roller.Dropped, and noted in the log file
+	// by a marker line at most once a FlushInterval.
+	OverflowPolicy OverflowPolicy `json:"overflow_policy" yaml:"overflow_policy"`
+	// SampleRate is the rate OverflowSample keeps writes at, one in
+	// SampleRate. The default is 10.
+	SampleRate int `json:"sample_rate" yaml:"sample_rate"`
+
+	// Durability: optional: DurabilityNever, DurabilityWrite, DurabilityBytes, DurabilityInterval, default DurabilityNever
+	// With any but DurabilityNever, the log file is also synced before it is
+	// rotated and on Close.
+	Durability Durability `json:"durability" yaml:"durability"`
+	// SyncBytes is how many bytes DurabilityBytes writes between syncs. The
+	// default is 1 MiB.
+	SyncBytes int64 `json:"sync_bytes" yaml:"sync_bytes"`
+	// SyncInterval is how often DurabilityInterval syncs. The default is one
+	// second.
+	SyncInterval time.Duration `json:"sync_interval" yaml:"sync_interval"`
+
+	// RecordDelimiter makes the log file rotate only between records ending
+	// with it, e.g. "\n" for lines, for writers which may write a record in
+	// several pieces. Data after the last delimiter written is held back
+	// until its record is complete, or until it reaches MaxSize (1 MiB if
+	// MaxSize is not set), Sync or Close. The default is to take every write
+	// as a whole record.
+	RecordDelimiter string `json:"record_delimiter" yaml:"record_delimiter"`
+
+	// EnforceMaxSize keeps MaxSize in force when RotateType is time based, so the
+	// log file is rotated on schedule and also whenever a write would make it
+	// larger than MaxSize. Backups of the same period are numbered, e.g.
+	// foo-20261018-1.log, foo-20261018-2.log.
+	EnforceMaxSize bool `json:"enforce_maxsize" yaml:"enforce_maxsize"`
+
+	// RotationPolicy replaces the rotation rules given by RotateType, RotateTime
+	// and EnforceMaxSize. MaxSize, if set, still limits the length of a single
+	// write.
+	RotationPolicy RotationPolicy `json:"-" yaml:"-"`
+
+	// BackupNameTemplate sets the name of backups, relative to the directory of
+	// the log file. It may contain these tokens:
+	//
+	//	{prefix}       the log file name without its extension, e.g. foo
+	//	{ext}          the extension of the log file name, e.g. .log
+	//	{time}         the backup timestamp in the default layout for RotateType
+	//	{time:layout}  the backup timestamp in the given time.Time layout
+	//	{seq}          the sequence number of backups of the same period, as -N,
+	//	               empty if there is none; appended to the name if missing
+	//	{host}         the host name
+	//	{pid}          the process id
+	//
+	// Exactly one {time} token is required. For example "{prefix}{ext}.{time:2006-01-02}"
+	// gives foo.log.2026-10-18 and "{time:2006/01/02}/{prefix}{ext}" gives
+	// 2026/10/18/foo.log. Only backups matching the template are cleaned up.
+	// The default is "{prefix}-{time}{seq}{ext}".
+	BackupNameTemplate string `json:"backup_name_template" yaml:"backup_name_template"`
+
+	// NumberedBackups names backups like logrotate does: foo.log.1 is the most
+	// recent backup, foo.log.2 the one before and so on, each rotation shifting
+	// the numbers up by one. MaxBackups drops the highest numbers and MaxAge
+	// goes by the modification time of the backups. It can't be combined with
+	// BackupNameTemplate.
+	NumberedBackups bool `json:"numbered_backups" yaml:"numbered_backups"`
+
+	// ArchiveDir is the directory rotated and compressed log files are moved
+	// to, and where MaxBackups and MaxAge look for them. A relative ArchiveDir
+	// is relative to the directory of the log file. It may be on a different
+	// filesystem, in which case backups are copied there and then removed. The
+	// default is to keep backups beside the log file.
+	ArchiveDir string `json:"archive_dir" yaml:"archive_dir"`
+
+	// PartitionLayout places backups in subdirectories of the backup directory
+	// named after their timestamp in this time.Time layout, e.g. "2006/01/02"
+	// puts a backup of Oct 18 2026 in 2026/10/18/. Subdirectories emptied by
+	// MaxBackups or MaxAge are removed. It can't be combined with
+	// NumberedBackups. The default is not to partition backups.
+	PartitionLayout string `json:"partition_layout" yaml:"partition_layout"`
+
 	Hook *Hook `json:"-" yaml:"-"`
 }
@@ -0,0 +1,128 @@
+package lumberjack
+
+import (
+	"fmt"
+	"os"
+)
+
+// OversizedWrite is what a Roller does with a write longer than MaxSize.
+type OversizedWrite string
+
+var (
+	// OversizedReject fails the write with ErrWriteTooLong.
+	OversizedReject OversizedWrite = "reject"
+	// OversizedSplit splits the write into pieces of MaxSize, written to
+	// consecutive log files, also when the log file is otherwise only rotated
+	// by time.
+	OversizedSplit OversizedWrite = "split"
+	// OversizedTruncate cuts the write to MaxSize, ending it with a marker
+	// noting how much was cut.
+	OversizedTruncate OversizedWrite = "truncate"
+	// OversizedOverflowFile appends the write to Options.OversizedPath
+	// instead, leaving a marker in the log file.
+	OversizedOverflowFile OversizedWrite = "overflow_file"
+)
+
+func IsLegalOversizedWrite(w OversizedWrite) bool {
+	return w == "" || w == OversizedReject || w == OversizedSplit || w == OversizedTruncate || w == OversizedOverflowFile
+}
+
+// writeOversized handles p, which is longer than maxWrite, according to
+// r.oversized. The caller holds r.mu.
+func (r *Roller) writeOversized(p []byte) (int, error) {
+	max := int(r.maxWrite)
+	switch r.oversized {
+	case OversizedSplit:
+		written := 0
+		for len(p) > 0 {
+			piece := p
+			if len(piece) > max {
+				piece = p[:max]
+			}
+			// rotating by time only, the policy doesn't see the log file
+			// is full
+			if r.size > 0 && r.size+int64(len(piece)) > r.maxWrite {
+				if err := r.rotate(); err != nil {
+					return written, err
+				}
+			}
+			n, err := r.writeFile(piece)
+			written += n
+			if err != nil {
+				return written, err
+			}
+			p = p[len(piece):]
+		}
+		return written, nil
+	case OversizedTruncate:
+		// keep as much as fits with the marker, which is never cut
+		cut := max
+		for cut > 0 && cut+len(r.truncatedMarker(len(p)-cut)) > max {
+			cut--
+		}
+		b := append(p[:cut:cut], r.truncatedMarker(len(p)-cut)...)
+		if _, err := r.writeFile(b); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	case OversizedOverflowFile:
+		if err := appendFile(r.oversizedPath, p); err != nil {
+			return 0, fmt.Errorf("can't write oversized write: %w", err)
+		}
+		marker := fmt.Sprintf("lumberjack: write of %d bytes moved to %s", len(p), r.oversizedPath)
+		if err := r.writeMarker(marker); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return 0, r.errWriteTooLong(len(p))
+}
+
+// rejectOversized reports whether writes longer than maxWrite fail.
+func (r *Roller) rejectOversized() bool {
+	return r.oversized == "" || r.oversized == OversizedReject
+}
+
+// errWriteTooLong returns the error of a write of n bytes, which is longer
+// than maxWrite.
+func (r *Roller) errWriteTooLong(n int) error {
+	return fmt.Errorf(
+		"write length %d, max size %d: %w", n, r.maxWrite, ErrWriteTooLong,
+	)
+}
+
+// truncatedMarker returns the marker ending a write of which n bytes were cut.
+// It ends the record, as the cut may have taken its delimiter.
+func (r *Roller) truncatedMarker(n int) string {
+	return fmt.Sprintf("... [%d bytes truncated]", n) + r.recordEnd()
+}
+
+// writeMarker writes a marker of lumberjack's own to the log file, as a record
+// of its own. It goes straight to the log file, after the last complete
+// record, so that it never lands in the middle of one. The caller holds r.mu.
+func (r *Roller) writeMarker(marker string) error {
+	_, err := r.writeFile([]byte(marker + r.recordEnd()))
+	return err
+}
+
+// recordEnd returns what ends the records lumberjack writes itself, the record
+// delimiter or else a newline.
+func (r *Roller) recordEnd() string {
+	if len(r.delimiter) > 0 {
+		return string(r.delimiter)
+	}
+	return "\n"
+}
+
+// appendFile appends p to the file name, creating it if needed.
+func appendFile(name string, p []byte) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(p); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
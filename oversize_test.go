@@ -0,0 +1,141 @@
+package lumberjack
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOversizedWrite(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestOversizedWrite", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	_, err := NewRoller(filename, &Options{OversizedWrite: OversizedOverflowFile})
+	notNil(err, t)
+
+	l, err := NewRoller(filename, &Options{MaxSize: 10, OversizedWrite: OversizedSplit})
+	isNil(err, t)
+	defer l.Close()
+
+	b := []byte("0123456789abcdefghijKLMN")
+	n, err := l.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	// the pieces go to consecutive log files
+	existsWithContent(filename, []byte("KLMN"), t)
+	files, err := l.oldLogFiles()
+	isNil(err, t)
+	equals(2, len(files), t)
+	existsWithContent(files[0].path, []byte("abcdefghij"), t)
+	existsWithContent(files[1].path, []byte("0123456789"), t)
+}
+
+func TestOversizedTruncate(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestOversizedTruncate", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{MaxSize: 30, OversizedWrite: OversizedTruncate})
+	isNil(err, t)
+	defer l.Close()
+
+	b := []byte(strings.Repeat("x", 100))
+	n, err := l.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	existsWithContent(filename, []byte("xxxxx... [95 bytes truncated]\n"), t)
+}
+
+func TestOversizedOverflowFile(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestOversizedOverflowFile", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	overflow := filepath.Join(dir, "oversized.log")
+	// the limit applies to time based rotation too
+	l, err := NewRoller(filename, &Options{
+		MaxSize:        10,
+		RotateType:     RotateDaily,
+		OversizedWrite: OversizedOverflowFile,
+		OversizedPath:  overflow,
+	})
+	isNil(err, t)
+	defer l.Close()
+
+	b := []byte("0123456789abcdefghij")
+	n, err := l.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	existsWithContent(overflow, b, t)
+	existsWithContent(filename, []byte("lumberjack: write of 20 bytes moved to "+overflow+"\n"), t)
+
+	l2, err := NewRoller(logFile(dir), &Options{MaxSize: 10, RotateType: RotateDaily})
+	isNil(err, t)
+	defer l2.Close()
+	_, err = l2.Write(b)
+	equals(true, errors.Is(err, ErrWriteTooLong), t)
+}
+
+func TestOversizedSplitTimeRotation(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestOversizedSplitTimeRotation", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{MaxSize: 10, RotateType: RotateDaily, OversizedWrite: OversizedSplit})
+	isNil(err, t)
+	defer l.Close()
+
+	_, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+	// the pieces go to consecutive log files, though the log file is only
+	// rotated daily
+	b := []byte("0123456789abcdefghijKLMN")
+	n, err := l.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	existsWithContent(filename, []byte("KLMN"), t)
+	files, err := l.oldLogFiles()
+	isNil(err, t)
+	equals(3, len(files), t)
+	fileCount(dir, 4, t)
+}
+
+func TestOversizedRecordDelimiter(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestOversizedRecordDelimiter", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{MaxSize: 40, RecordDelimiter: ";", OversizedWrite: OversizedTruncate})
+	isNil(err, t)
+	defer l.Close()
+
+	_, err = l.Write([]byte("first;partial-rec"))
+	isNil(err, t)
+	// the record is truncated whole, and ended with the delimiter
+	_, err = l.Write([]byte(strings.Repeat("x", 100) + ";"))
+	isNil(err, t)
+	files, err := l.oldLogFiles()
+	isNil(err, t)
+	equals(1, len(files), t)
+	existsWithContent(files[0].path, []byte("first;"), t)
+	existsWithContent(filename, []byte("partial-recxxxx... [97 bytes truncated];"), t)
+
+	overflow := filepath.Join(dir, "oversized.log")
+	l.oversized = OversizedOverflowFile
+	l.oversizedPath = overflow
+	_, err = l.Write([]byte("next-rec"))
+	isNil(err, t)
+	b := []byte(strings.Repeat("y", 50) + ";")
+	_, err = l.Write(b)
+	isNil(err, t)
+	existsWithContent(overflow, append([]byte("next-rec"), b...), t)
+	existsWithContent(filename, []byte("lumberjack: write of 59 bytes moved to "+overflow+";"), t)
+}
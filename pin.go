@@ -0,0 +1,93 @@
+package lumberjack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// pinSuffix is the suffix of the marker files pinning backups. The marker of
+// foo-20261018.log, or of foo-20261018.log.gz, is foo-20261018.log.pin.
+const pinSuffix = ".pin"
+
+// Pin keeps the backup name from being deleted by MaxBackups, MaxAge, the
+// retention tiers, MaxTotalSize and MinFreeSpace until it is unpinned. Pinned
+// backups don't count towards MaxBackups, but their size counts towards
+// MaxTotalSize. name is the path of the backup, or its name in the backup
+// directory, compressed or not. The pin is kept in a marker file beside the
+// backup, so it lasts across restarts.
+func (r *Roller) Pin(name string) error {
+	path, err := r.backupPath(name)
+	if err != nil {
+		return err
+	}
+	// don't race with the mill deciding to remove the backup
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
+	if err := ioutil.WriteFile(pinPath(path), nil, 0644); err != nil {
+		return fmt.Errorf("can't pin backup: %w", err)
+	}
+	return nil
+}
+
+// Unpin lets the backup name be deleted again, see Pin.
+func (r *Roller) Unpin(name string) error {
+	path, err := r.backupPath(name)
+	if err != nil {
+		return err
+	}
+	r.millMu.Lock()
+	defer r.millMu.Unlock()
+	if err := os.Remove(pinPath(path)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can't unpin backup: %w", err)
+	}
+	return nil
+}
+
+// backupPath returns the path of the backup name, which must exist and be
+// one of the roller's backups.
+func (r *Roller) backupPath(name string) (string, error) {
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.backupDir(), name)
+	}
+	if !r.isBackupPath(path) {
+		return "", fmt.Errorf("%s is not a backup", name)
+	}
+	base := trimCompressed(path)
+	candidates := []string{base}
+	for _, suffix := range compressSuffixes() {
+		candidates = append(candidates, base+suffix)
+	}
+	for _, p := range candidates {
+		if _, err := osStat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no backup %s", name)
+}
+
+// pinPath returns the path of the marker pinning the backup at path.
+func pinPath(path string) string {
+	return trimCompressed(path) + pinSuffix
+}
+
+// pinned reports whether the backup at path is pinned.
+func pinned(path string) bool {
+	_, err := osStat(pinPath(path))
+	return err == nil
+}
+
+// splitPinned splits files into those that are pinned and those that are not,
+// keeping their order.
+func splitPinned(files []logInfo) (unpinned, pinnedFiles []logInfo) {
+	for _, f := range files {
+		if pinned(f.path) {
+			pinnedFiles = append(pinnedFiles, f)
+		} else {
+			unpinned = append(unpinned, f)
+		}
+	}
+	return unpinned, pinnedFiles
+}
@@ -0,0 +1,95 @@
+package lumberjack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPin(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestPin", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{MaxBackups: 1, Compress: true, MaxSize: 10})
+	isNil(err, t)
+	defer l.Close()
+
+	notNil(l.Pin("foobar-20000101000000.log"), t)
+
+	// only backups can be pinned, not the log file or files elsewhere
+	outside := filepath.Join(filepath.Dir(dir), filepath.Base(backupFile(dir)))
+	isNil(ioutil.WriteFile(outside, []byte("other"), 0644), t)
+	defer os.Remove(outside)
+	notNil(l.Pin("foobar.log"), t)
+	notNil(l.Pin(filename), t)
+	notNil(l.Pin(filepath.Join("..", filepath.Base(outside))), t)
+	notExist(filename+pinSuffix, t)
+	notExist(pinPath(outside), t)
+
+	b := []byte("boo!")
+	_, err = l.Write(b)
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	first := backupFile(dir)
+
+	// we need to wait a little bit since the files get compressed on a
+	// different goroutine.
+	<-time.After(300 * time.Millisecond)
+
+	// the pin lasts through compression
+	isNil(l.Pin(filepath.Base(first)), t)
+	exists(first+compressSuffix, t)
+
+	for _, s := range []string{"foooooo!", "baaar!"} {
+		_, err = l.Write([]byte(s))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+		<-time.After(300 * time.Millisecond)
+	}
+
+	// the pinned backup doesn't count towards MaxBackups
+	exists(first+compressSuffix, t)
+	exists(backupFile(dir)+compressSuffix, t)
+	exists(first+pinSuffix, t)
+	fileCount(dir, 4, t)
+
+	isNil(l.Unpin(first+compressSuffix), t)
+	notExist(first+pinSuffix, t)
+	isNil(l.millRunOnce(), t)
+	notExist(first+compressSuffix, t)
+	fileCount(dir, 2, t)
+}
+
+func TestPinNumberedBackups(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestPinNumberedBackups", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	isNil(ioutil.WriteFile(filename, []byte("one"), 0644), t)
+	l, err := NewRoller(filename, &Options{NumberedBackups: true, MaxBackups: 1, MaxSize: 10})
+	isNil(err, t)
+	defer l.Close()
+
+	isNil(l.Rotate(), t)
+	isNil(l.Pin(filename+".1"), t)
+	for _, s := range []string{"two", "three"} {
+		_, err = l.Write([]byte(s))
+		isNil(err, t)
+		isNil(l.Rotate(), t)
+	}
+	isNil(l.millRunOnce(), t)
+
+	// the pin moves with the backup
+	existsWithContent(filename+".1", []byte("three"), t)
+	existsWithContent(filename+".3", []byte("one"), t)
+	exists(filename+".3"+pinSuffix, t)
+	notExist(filename+".2", t)
+	fileCount(dir, 4, t)
+}
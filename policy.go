@@ -0,0 +1,169 @@
+package lumberjack
+
+import "time"
+
+// RotationState describes the current log file to a RotationPolicy.
+type RotationState struct {
+	// Size is the current size of the log file in bytes.
+	Size int64
+	// WriteLen is the length of the pending write, or 0 if there is none.
+	WriteLen int64
+	// Opened is the time the current log file was opened.
+	Opened time.Time
+	// Now is the current time.
+	Now time.Time
+}
+
+// RotationPolicy decides when a Roller rotates its log file and which time is
+// encoded in the name of the backup.
+type RotationPolicy interface {
+	// ShouldRotate reports whether the log file should be rotated before the
+	// pending write.
+	ShouldRotate(s RotationState) bool
+	// NextDeadline returns the first time after now at which the log file
+	// must be rotated, or the zero time if the policy is not time based.
+	NextDeadline(now time.Time) time.Time
+	// BackupTimestamp returns the time encoded in the name of the backup the
+	// log file is moved to.
+	BackupTimestamp(s RotationState) time.Time
+}
+
+// SizePolicy rotates the log file whenever a write would make it larger than
+// MaxSize bytes.
+type SizePolicy struct {
+	MaxSize int64
+}
+
+// ShouldRotate implements RotationPolicy.
+func (p SizePolicy) ShouldRotate(s RotationState) bool {
+	return s.Size+s.WriteLen > p.MaxSize
+}
+
+// NextDeadline implements RotationPolicy.
+func (p SizePolicy) NextDeadline(now time.Time) time.Time {
+	return time.Time{}
+}
+
+// BackupTimestamp implements RotationPolicy, stamping backups with the time
+// of rotation.
+func (p SizePolicy) BackupTimestamp(s RotationState) time.Time {
+	return s.Now
+}
+
+// TimePolicy rotates the log file on the schedule of a time based RotateType,
+// see Options.RotateTime for the meaning of Every and Options.WeekStart for
+// WeekStart.
+type TimePolicy struct {
+	Type      RotateType
+	Every     uint
+	LocalTime bool
+	WeekStart time.Weekday
+}
+
+// ShouldRotate implements RotationPolicy.
+func (p TimePolicy) ShouldRotate(s RotationState) bool {
+	deadline := p.NextDeadline(s.Opened)
+	return !deadline.IsZero() && !s.Now.Before(deadline)
+}
+
+// NextDeadline implements RotationPolicy.
+func (p TimePolicy) NextDeadline(now time.Time) time.Time {
+	if p.Type == RotateDateNotNeed || p.Type == RotateSize {
+		return time.Time{}
+	}
+	every := p.Every
+	if every == 0 {
+		every = 1
+	}
+	remain := calRemainderSecondToNextRotateTime(now, p.Type, int(every), p.LocalTime, p.WeekStart)
+	return time.Unix(now.Unix()+remain, 0)
+}
+
+// BackupTimestamp implements RotationPolicy, stamping backups with the time
+// the log file was opened, which lies in the period it covers. Weekly and
+// monthly backups are stamped with the first day of that week or month.
+func (p TimePolicy) BackupTimestamp(s RotationState) time.Time {
+	t := s.Opened.Local()
+	if !p.LocalTime {
+		t = t.UTC()
+	}
+	y, m, d := t.Date()
+	switch p.Type {
+	case RotateWeekly:
+		d -= (int(t.Weekday()) - int(p.WeekStart) + 7) % 7
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	case RotateMonthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	}
+	return s.Opened
+}
+
+// Any returns a policy that rotates as soon as one of the given policies
+// would. Backups are stamped by the first policy.
+func Any(policies ...RotationPolicy) RotationPolicy {
+	return anyPolicy(policies)
+}
+
+type anyPolicy []RotationPolicy
+
+func (a anyPolicy) ShouldRotate(s RotationState) bool {
+	for _, p := range a {
+		if p.ShouldRotate(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a anyPolicy) NextDeadline(now time.Time) time.Time {
+	var next time.Time
+	for _, p := range a {
+		d := p.NextDeadline(now)
+		if !d.IsZero() && (next.IsZero() || d.Before(next)) {
+			next = d
+		}
+	}
+	return next
+}
+
+func (a anyPolicy) BackupTimestamp(s RotationState) time.Time {
+	if len(a) == 0 {
+		return s.Now
+	}
+	return a[0].BackupTimestamp(s)
+}
+
+// All returns a policy that rotates only when all of the given policies
+// would. Backups are stamped by the first policy.
+func All(policies ...RotationPolicy) RotationPolicy {
+	return allPolicy(policies)
+}
+
+type allPolicy []RotationPolicy
+
+func (a allPolicy) ShouldRotate(s RotationState) bool {
+	for _, p := range a {
+		if !p.ShouldRotate(s) {
+			return false
+		}
+	}
+	return len(a) > 0
+}
+
+func (a allPolicy) NextDeadline(now time.Time) time.Time {
+	var next time.Time
+	for _, p := range a {
+		d := p.NextDeadline(now)
+		if d.After(next) {
+			next = d
+		}
+	}
+	return next
+}
+
+func (a allPolicy) BackupTimestamp(s RotationState) time.Time {
+	if len(a) == 0 {
+		return s.Now
+	}
+	return a[0].BackupTimestamp(s)
+}
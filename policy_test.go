@@ -0,0 +1,83 @@
+package lumberjack
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nonEmptyPolicy rotates before every write to a non-empty log file.
+type nonEmptyPolicy struct{}
+
+func (nonEmptyPolicy) ShouldRotate(s RotationState) bool         { return s.Size > 0 }
+func (nonEmptyPolicy) NextDeadline(now time.Time) time.Time      { return time.Time{} }
+func (nonEmptyPolicy) BackupTimestamp(s RotationState) time.Time { return s.Now }
+
+func TestPolicyCombinators(t *testing.T) {
+	now := time.Date(2022, 10, 1, 10, 30, 0, 0, time.UTC)
+	size := SizePolicy{MaxSize: 10}
+	hourly := TimePolicy{Type: RotateHourly, Every: 1}
+
+	small := RotationState{Size: 4, WriteLen: 4, Opened: now, Now: now.Add(time.Minute)}
+	big := RotationState{Size: 4, WriteLen: 8, Opened: now, Now: now.Add(time.Minute)}
+	late := RotationState{Size: 4, WriteLen: 4, Opened: now, Now: now.Add(time.Hour)}
+	bigAndLate := RotationState{Size: 4, WriteLen: 8, Opened: now, Now: now.Add(time.Hour)}
+
+	equals(false, Any(size, hourly).ShouldRotate(small), t)
+	equals(true, Any(size, hourly).ShouldRotate(big), t)
+	equals(true, Any(size, hourly).ShouldRotate(late), t)
+	equals(false, All(size, hourly).ShouldRotate(big), t)
+	equals(false, All(size, hourly).ShouldRotate(late), t)
+	equals(true, All(size, hourly).ShouldRotate(bigAndLate), t)
+	equals(false, All().ShouldRotate(bigAndLate), t)
+
+	daily := TimePolicy{Type: RotateDaily, Every: 1}
+	nextHour := time.Date(2022, 10, 1, 11, 0, 0, 0, time.UTC)
+	nextDay := time.Date(2022, 10, 2, 0, 0, 0, 0, time.UTC)
+	equals(true, nextHour.Equal(Any(size, daily, hourly).NextDeadline(now)), t)
+	equals(true, nextDay.Equal(All(size, daily, hourly).NextDeadline(now)), t)
+	equals(true, size.NextDeadline(now).IsZero(), t)
+
+	equals(now, Any(hourly, size).BackupTimestamp(late), t)
+	equals(late.Now, Any(size, hourly).BackupTimestamp(late), t)
+}
+
+func TestCustomRotationPolicy(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestCustomRotationPolicy", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	r, err := NewRoller(filename, &Options{RotationPolicy: nonEmptyPolicy{}})
+	isNil(err, t)
+	defer r.Close()
+
+	b := []byte("boo!")
+	n, err := r.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	existsWithContent(filename, b, t)
+
+	// MaxSize isn't set, so writes of any length are accepted
+	equals(int64(0), r.maxWrite, t)
+	b2 := bytes.Repeat([]byte("a"), 64)
+	n, err = r.Write(b2)
+	isNil(err, t)
+	equals(len(b2), n, t)
+
+	b3 := []byte("baaaaaar!")
+	n, err = r.Write(b3)
+	isNil(err, t)
+	equals(len(b3), n, t)
+
+	// the clock didn't move, so the second backup gets a sequence number
+	existsWithContent(filename, b3, t)
+	existsWithContent(backupFile(dir), b, t)
+	second := filepath.Join(dir, "foobar-"+fakeTime().UTC().Format(backupTimeFormat)+"-1.log")
+	info, err := os.Stat(second)
+	isNil(err, t)
+	equals(int64(len(b2)), info.Size(), t)
+	fileCount(dir, 3, t)
+}
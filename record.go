@@ -0,0 +1,69 @@
+package lumberjack
+
+import "bytes"
+
+// defaultMaxRecordSize is how much of an incomplete record is held back when
+// there is no MaxSize.
+const defaultMaxRecordSize = 1024 * 1024
+
+// writeDelimited writes the complete records of p, along with the incomplete
+// record held back before it, to the log file, and holds back the rest. Each
+// record goes whole to one log file, records longer than maxWrite being
+// oversized writes. The caller holds r.mu.
+func (r *Roller) writeDelimited(p []byte) (int, error) {
+	r.partial = append(r.partial, p...)
+	end := bytes.LastIndex(r.partial, r.delimiter)
+	if end >= 0 {
+		end += len(r.delimiter)
+	} else if int64(len(r.partial)) < r.maxRecordSize() {
+		return len(p), nil
+	} else {
+		// too long to hold back, write it as it is
+		end = len(r.partial)
+	}
+
+	r.lens = r.recordLens(r.partial[:end], r.lens[:0])
+	if err := r.writeRecords(r.partial[:end], r.lens); err != nil {
+		// the rest of the records are lost with the write
+		r.partial = r.partial[:0]
+		return 0, err
+	}
+	r.partial = append(r.partial[:0], r.partial[end:]...)
+	return len(p), nil
+}
+
+// recordLens appends the lengths of the records in data to lens. The last
+// record may lack a delimiter.
+func (r *Roller) recordLens(data []byte, lens []int) []int {
+	for len(data) > 0 {
+		l := len(data)
+		if i := bytes.Index(data, r.delimiter); i >= 0 {
+			l = i + len(r.delimiter)
+		}
+		lens = append(lens, l)
+		data = data[l:]
+	}
+	return lens
+}
+
+// flushPartial writes the incomplete record held back, if any, to the log
+// file. The caller holds r.mu.
+func (r *Roller) flushPartial() error {
+	if len(r.partial) == 0 || r.file == nil {
+		return nil
+	}
+	_, err := r.writeWhole(r.partial)
+	r.partial = r.partial[:0]
+	return err
+}
+
+// maxRecordSize returns how much of an incomplete record may be held back.
+func (r *Roller) maxRecordSize() int64 {
+	if r.maxSize > 0 {
+		return r.maxSize
+	}
+	if r.maxWrite > 0 && r.maxWrite < defaultMaxRecordSize {
+		return r.maxWrite
+	}
+	return defaultMaxRecordSize
+}
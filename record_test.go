@@ -0,0 +1,123 @@
+package lumberjack
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordDelimiter(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestRecordDelimiter", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{MaxSize: 20, RecordDelimiter: "\n"})
+	isNil(err, t)
+	defer l.Close()
+
+	write := func(s string) {
+		n, err := l.Write([]byte(s))
+		isNil(err, t)
+		equals(len(s), n, t)
+	}
+	write("hello ")
+	// the incomplete record is held back
+	existsWithContent(filename, []byte{}, t)
+	write("world\nsec")
+	existsWithContent(filename, []byte("hello world\n"), t)
+
+	newFakeTime()
+	// the record would make the log file too large, it goes whole to the
+	// next one
+	write("ond ")
+	write("line\n")
+	existsWithContent(backupFile(dir), []byte("hello world\n"), t)
+	existsWithContent(filename, []byte("second line\n"), t)
+
+	write("tail")
+	isNil(l.Sync(), t)
+	existsWithContent(filename, []byte("second line\ntail"), t)
+
+	write(" end")
+	isNil(l.Close(), t)
+	existsWithContent(filename, []byte("second line\ntail end"), t)
+}
+
+func TestRecordDelimiterLongRecord(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestRecordDelimiterLongRecord", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{MaxSize: 10, RecordDelimiter: ";;"})
+	isNil(err, t)
+	defer l.Close()
+
+	_, err = l.Write([]byte("a;;b"))
+	isNil(err, t)
+	existsWithContent(filename, []byte("a;;"), t)
+
+	// a record as long as MaxSize isn't held back any longer
+	newFakeTime()
+	_, err = l.Write([]byte("cdefghijk"))
+	isNil(err, t)
+	existsWithContent(backupFile(dir), []byte("a;;"), t)
+	existsWithContent(filename, []byte("bcdefghijk"), t)
+}
+
+func TestRecordDelimiterOversized(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestRecordDelimiterOversized", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{MaxSize: 200, RecordDelimiter: "\n"})
+	isNil(err, t)
+	defer l.Close()
+
+	// a write longer than MaxSize is fine as long as its records aren't
+	b := []byte(strings.Repeat("x", 99) + "\n" + strings.Repeat("y", 99) + "\n" + strings.Repeat("z", 50))
+	n, err := l.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	existsWithContent(filename, b[:200], t)
+
+	// a record longer than MaxSize is an oversized write, which leaves the
+	// log file alone
+	_, err = l.Write([]byte(strings.Repeat("z", 160) + "\n"))
+	equals(true, errors.Is(err, ErrWriteTooLong), t)
+	existsWithContent(filename, b[:200], t)
+	fileCount(dir, 1, t)
+}
+
+func TestRecordDelimiterMarker(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestRecordDelimiterMarker", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l, err := NewRoller(filename, &Options{RecordDelimiter: "\n"})
+	isNil(err, t)
+	// nothing drains the buffer until told to
+	l.async = newAsyncWriter(4, time.Hour, OverflowDropNewest, 0)
+
+	_, err = l.Write([]byte("a\nha"))
+	isNil(err, t)
+	l.drain(false)
+	_, err = l.Write([]byte("lf\n."))
+	isNil(err, t)
+	_, err = l.Write([]byte("xy"))
+	equals(ErrBufferFull, err, t)
+
+	// the marker doesn't land in the middle of the record held back
+	select {
+	case <-l.async.kick:
+	default:
+	}
+	go l.runDrain()
+	isNil(l.Close(), t)
+	existsWithContent(filename, []byte("a\n"+dropMarker(1, 2)+"half\n."), t)
+}
@@ -0,0 +1,128 @@
+package lumberjack
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tmpSuffix is the suffix of the files compressed backups and backups copied
+// to another filesystem are written to, before they are renamed into place.
+const tmpSuffix = ".tmp"
+
+// recoverCompression cleans up after compressions and moves of backups which
+// were interrupted by a crash. Temporary files are removed. A backup which
+// exists both plain and compressed was compressed, but the plain one not yet
+// removed, so it is removed now; unless the compressed one is incomplete, as
+// older versions compressed in place, then that is removed to be redone.
+func (r *Roller) recoverCompression() error {
+	err := r.eachBackupDirFile(func(path string) error {
+		if strings.HasSuffix(path, tmpSuffix) && r.isBackupPath(strings.TrimSuffix(path, tmpSuffix)) {
+			return os.Remove(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	files, err := r.oldLogFiles()
+	if err != nil {
+		return err
+	}
+	plain := make(map[string]bool)
+	for _, f := range files {
+		if !isCompressed(f.Name()) {
+			plain[f.path] = true
+		}
+	}
+	for _, f := range files {
+		name := trimCompressed(f.path)
+		if !isCompressed(f.Name()) || !plain[name] {
+			continue
+		}
+		if verifyCompressed(f.path) == nil {
+			if err = os.Remove(name); err == nil {
+				err = r.recordCompress(name, f.path)
+			}
+		} else {
+			err = os.Remove(f.path)
+		}
+		if err != nil {
+			return fmt.Errorf("can't recover compressed backup: %w", err)
+		}
+		delete(plain, name)
+	}
+	return nil
+}
+
+// eachBackupDirFile calls fn with the path of each file in the backup
+// directory, or in the directories below it backups are nested in.
+func (r *Roller) eachBackupDirFile(fn func(path string) error) error {
+	dir := r.backupDir()
+	if r.nested() {
+		return r.walkBackupDir(func(path string, _ os.FileInfo) error {
+			return fn(path)
+		})
+	}
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if err := fn(filepath.Join(dir, f.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBackupPath reports whether path is the path of one of the roller's
+// backups, compressed or not.
+func (r *Roller) isBackupPath(path string) bool {
+	if r.numberedBackups {
+		_, ok := r.numberedIndex(filepath.Base(path))
+		return ok && filepath.Dir(path) == filepath.Clean(r.backupDir())
+	}
+	_, _, err := r.parseBackupPath(path)
+	return err == nil
+}
+
+// verifyCompressed checks that the compressed backup at path decompresses
+// completely.
+func verifyCompressed(path string) error {
+	c := compressorFor(path)
+	if c == nil {
+		return fmt.Errorf("%s is not compressed", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zr, err := c.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	_, err = io.Copy(ioutil.Discard, zr)
+	return err
+}
+
+// syncDir flushes the entries of dir to disk, so that a rename in it lasts
+// through a crash. It is best effort, not every platform can sync directories.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,93 @@
+package lumberjack
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecoverCompression(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestRecoverCompression", t)
+	defer os.RemoveAll(dir)
+
+	// a compression which was interrupted while writing
+	partial := backupFile(dir)
+	isNil(ioutil.WriteFile(partial, []byte("boo!"), 0644), t)
+	isNil(ioutil.WriteFile(partial+compressSuffix+tmpSuffix, []byte("bo"), 0644), t)
+
+	// a compression which was interrupted before removing the source
+	newFakeTime()
+	done := backupFile(dir)
+	isNil(ioutil.WriteFile(done, []byte("foo!"), 0644), t)
+	isNil(ioutil.WriteFile(done+compressSuffix, gzipped([]byte("foo!"), t), 0644), t)
+
+	// a copy to another filesystem which was interrupted
+	newFakeTime()
+	copied := backupFile(dir) + tmpSuffix
+	isNil(ioutil.WriteFile(copied, []byte("bar"), 0644), t)
+
+	// not ours
+	other := filepath.Join(dir, "other.log"+tmpSuffix)
+	isNil(ioutil.WriteFile(other, []byte("other"), 0644), t)
+
+	l, err := NewRoller(logFile(dir), &Options{MaxSize: 10})
+	isNil(err, t)
+	defer l.Close()
+
+	existsWithContent(partial, []byte("boo!"), t)
+	notExist(partial+compressSuffix+tmpSuffix, t)
+	existsWithContent(done+compressSuffix, gzipped([]byte("foo!"), t), t)
+	notExist(done, t)
+	notExist(copied, t)
+	exists(other, t)
+	// the log file, both backups and the other file
+	fileCount(dir, 4, t)
+}
+
+func TestCompressKeepsExisting(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestCompressKeepsExisting", t)
+	defer os.RemoveAll(dir)
+
+	src := backupFile(dir)
+	dst := src + compressSuffix
+	isNil(ioutil.WriteFile(src, []byte("second"), 0644), t)
+	isNil(ioutil.WriteFile(dst, gzipped([]byte("first"), t), 0644), t)
+
+	err := compressLogFile(context.Background(), src, dst, GzipCompressor{}, nil)
+	notNil(err, t)
+	existsWithContent(dst, gzipped([]byte("first"), t), t)
+	existsWithContent(src, []byte("second"), t)
+	notExist(dst+tmpSuffix, t)
+}
+
+func TestRecoverCompressionPartitioned(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestRecoverCompressionPartitioned", t)
+	defer os.RemoveAll(dir)
+
+	// a compression which was interrupted while writing, in its partition
+	partial := filepath.Join(dir, fakeTime().UTC().Format("2006/01/02"), filepath.Base(backupFile(dir)))
+	isNil(os.MkdirAll(filepath.Dir(partial), 0755), t)
+	isNil(ioutil.WriteFile(partial+compressSuffix+tmpSuffix, []byte("bo"), 0644), t)
+
+	// a directory which isn't ours, and which recovery needn't be able to
+	// read
+	private := filepath.Join(dir, "private")
+	isNil(os.Mkdir(private, 0755), t)
+	other := filepath.Join(private, filepath.Base(partial)+compressSuffix+tmpSuffix)
+	isNil(ioutil.WriteFile(other, []byte("other"), 0644), t)
+	isNil(os.Chmod(private, 0), t)
+	defer os.Chmod(private, 0755)
+
+	l, err := NewRoller(logFile(dir), &Options{PartitionLayout: "2006/01/02"})
+	isNil(err, t)
+	defer l.Close()
+
+	notExist(partial+compressSuffix+tmpSuffix, t)
+	isNil(os.Chmod(private, 0755), t)
+	exists(other, t)
+}
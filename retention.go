@@ -0,0 +1,115 @@
+package lumberjack
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RetentionTier keeps backups younger than Within, one per period of Every.
+// Tiers are evaluated by the age of the backups according to the timestamp in
+// their names, so that e.g.
+//
+//	[]RetentionTier{
+//		{Within: 24 * time.Hour},
+//		{Within: 30 * 24 * time.Hour, Every: RotateDaily},
+//		{Within: 365 * 24 * time.Hour, Every: RotateMonthly},
+//	}
+//
+// keeps every backup for a day, one per day for 30 days and one per month for
+// a year. The oldest backup of each period is kept.
+type RetentionTier struct {
+	// Within is the age up to which backups fall in this tier.
+	Within time.Duration `json:"within" yaml:"within"`
+	// Every: optional: RotateMinute, RotateHourly, RotateDaily, RotateWeekly,
+	// RotateMonthly, default keeps every backup
+	Every RotateType `json:"every" yaml:"every"`
+}
+
+// checkRetention returns the tiers sorted by Within, or an error if one of them
+// is invalid.
+func checkRetention(tiers []RetentionTier) ([]RetentionTier, error) {
+	sorted := append([]RetentionTier(nil), tiers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Within < sorted[j].Within
+	})
+	for _, tier := range sorted {
+		if tier.Within <= 0 {
+			return nil, fmt.Errorf("retention tier %v must have a positive Within", tier)
+		}
+		if tier.Every != "" && tier.Every.period() == 0 {
+			return nil, fmt.Errorf("retention tier %v can't keep one backup per %q", tier, tier.Every)
+		}
+	}
+	return sorted, nil
+}
+
+// retain splits files, sorted newest first, into those the retention tiers
+// keep and those they remove, both sorted newest first. Backups older than
+// all tiers are removed.
+func (r *Roller) retain(files []logInfo, now time.Time) (keep, remove []logInfo) {
+	kept := make(map[string]bool)
+	buckets := make(map[string]bool)
+	// oldest first, so that the first backup of a period is kept
+	for i := len(files) - 1; i >= 0; i-- {
+		f := files[i]
+		// a backup and its compressed copy count as one
+		name := trimCompressed(f.path)
+		age := now.Sub(f.timestamp)
+		tier := -1
+		for j, t := range r.retention {
+			if age < t.Within {
+				tier = j
+				break
+			}
+		}
+		switch {
+		case kept[name]:
+		case tier < 0:
+			remove = append(remove, f)
+			continue
+		case r.retention[tier].Every != "":
+			start := r.periodStart(f.timestamp, r.retention[tier].Every)
+			bucket := fmt.Sprintf("%d/%d", tier, start.Unix())
+			if buckets[bucket] {
+				remove = append(remove, f)
+				continue
+			}
+			buckets[bucket] = true
+		}
+		kept[name] = true
+		keep = append(keep, f)
+	}
+	reverse(keep)
+	reverse(remove)
+	return keep, remove
+}
+
+// periodStart returns the start of the period of type typ t lies in, in local
+// time if the roller uses local time and in UTC otherwise.
+func (r *Roller) periodStart(t time.Time, typ RotateType) time.Time {
+	loc := time.UTC
+	if r.localTime {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	y, m, d := t.Date()
+	switch typ {
+	case RotateMonthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case RotateWeekly:
+		d -= (int(t.Weekday()) - int(r.weekStart) + 7) % 7
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	case RotateDaily:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	case RotateHourly:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc)
+	}
+	return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc)
+}
+
+func reverse(files []logInfo) {
+	for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+		files[i], files[j] = files[j], files[i]
+	}
+}
@@ -0,0 +1,98 @@
+package lumberjack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetain(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	r := &Roller{}
+	tiers, err := checkRetention([]RetentionTier{
+		{Within: 365 * 24 * time.Hour, Every: RotateMonthly},
+		{Within: 24 * time.Hour},
+		{Within: 30 * 24 * time.Hour, Every: RotateDaily},
+	})
+	isNil(err, t)
+	r.retention = tiers
+
+	// a backup every 6 hours for 400 days, newest first
+	var files []logInfo
+	for ts := now.Add(-6 * time.Hour); ts.After(now.Add(-400 * 24 * time.Hour)); ts = ts.Add(-6 * time.Hour) {
+		files = append(files, logInfo{timestamp: ts, path: ts.Format(backupTimeFormat)})
+	}
+	keep, remove := r.retain(files, now)
+	equals(len(files), len(keep)+len(remove), t)
+
+	var kept []string
+	for _, f := range keep {
+		kept = append(kept, f.timestamp.Format("2006-01-02 15"))
+	}
+	// every backup of the last day
+	equals("2026-10-18 06", kept[0], t)
+	equals("2026-10-17 18", kept[2], t)
+	// then the first one of each day
+	equals("2026-10-17 00", kept[3], t)
+	equals("2026-10-16 00", kept[4], t)
+	equals("2026-09-19 00", kept[31], t)
+	// the day crossing into the monthly tier keeps its first backup in the
+	// daily tier until it ages out of it too
+	equals("2026-09-18 18", kept[32], t)
+	// then the first one of each month
+	equals("2026-09-01 00", kept[33], t)
+	equals("2025-11-01 00", kept[len(kept)-2], t)
+	equals("2025-10-18 18", kept[len(kept)-1], t)
+	equals(33+12, len(kept), t)
+
+	for i := 1; i < len(remove); i++ {
+		equals(true, remove[i-1].timestamp.After(remove[i].timestamp), t)
+	}
+
+	_, err = checkRetention([]RetentionTier{{Within: time.Hour, Every: RotateCron}})
+	notNil(err, t)
+	_, err = checkRetention([]RetentionTier{{Every: RotateDaily}})
+	notNil(err, t)
+}
+
+func TestRetentionTiers(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	dir := makeTempDir("TestRetentionTiers", t)
+	defer os.RemoveAll(dir)
+
+	// backups at 00:00 and 12:00 of the last 3 days
+	for d := 1; d <= 3; d++ {
+		for _, h := range []int{0, 12} {
+			ts := fakeCurrentTime.Add(-time.Duration(d) * 24 * time.Hour).Add(time.Duration(h-12) * time.Hour)
+			name := filepath.Join(dir, fmt.Sprintf("foobar-%s.log", ts.Format(backupTimeFormat)))
+			isNil(ioutil.WriteFile(name, []byte("boo!"), 0644), t)
+		}
+	}
+
+	l, err := NewRoller(logFile(dir), &Options{
+		MaxSize: 10,
+		Retention: []RetentionTier{
+			{Within: 36 * time.Hour},
+			{Within: 72 * time.Hour, Every: RotateDaily},
+		},
+	})
+	isNil(err, t)
+	defer l.Close()
+
+	// we need to wait a little bit since the files get deleted on a different
+	// goroutine.
+	<-time.After(10 * time.Millisecond)
+
+	// both of yesterday, the first of the day before, none of the day
+	// before that
+	exists(filepath.Join(dir, "foobar-20261017120000.log"), t)
+	exists(filepath.Join(dir, "foobar-20261017000000.log"), t)
+	exists(filepath.Join(dir, "foobar-20261016000000.log"), t)
+	notExist(filepath.Join(dir, "foobar-20261016120000.log"), t)
+	notExist(filepath.Join(dir, "foobar-20261015000000.log"), t)
+	fileCount(dir, 4, t)
+}
@@ -1,15 +1,40 @@
 package lumberjack
 
+import "time"
+
 type RotateType string
 
 var (
 	RotateDateNotNeed RotateType = ""
+	RotateMonthly     RotateType = "monthly"
+	RotateWeekly      RotateType = "weekly"
 	RotateDaily       RotateType = "daily"
 	RotateHourly      RotateType = "hourly"
 	RotateMinute      RotateType = "minute"
 	RotateSize        RotateType = "size"
+	// RotateCron rotates on the schedule given by Options.RotateCron
+	RotateCron RotateType = "cron"
 )
 
 func IsLegalRotateType(t RotateType) bool {
-	return t == RotateDateNotNeed || t == RotateDaily || t == RotateHourly || t == RotateSize || t == RotateMinute
+	return t == RotateDateNotNeed || t == RotateMonthly || t == RotateWeekly || t == RotateDaily ||
+		t == RotateHourly || t == RotateSize || t == RotateMinute || t == RotateCron
+}
+
+// period returns the length of one rotate period, taking a month as 31 days,
+// or 0 if the periods of t have no fixed length.
+func (t RotateType) period() time.Duration {
+	switch t {
+	case RotateMonthly:
+		return 31 * 24 * time.Hour
+	case RotateWeekly:
+		return 7 * 24 * time.Hour
+	case RotateDaily:
+		return 24 * time.Hour
+	case RotateHourly:
+		return time.Hour
+	case RotateMinute:
+		return time.Minute
+	}
+	return 0
 }
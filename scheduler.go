@@ -0,0 +1,100 @@
+package lumberjack
+
+import "time"
+
+const (
+	// minScheduleWait is the shortest time the scheduler sleeps between
+	// checks.
+	minScheduleWait = 10 * time.Millisecond
+	// maxScheduleBackoff is the longest time the scheduler waits to retry a
+	// rotation which didn't happen at its deadline.
+	maxScheduleBackoff = time.Minute
+)
+
+// startScheduler starts the goroutine that rotates the log file at the
+// deadlines of the rotation policy, whether or not anything is written.
+func (r *Roller) startScheduler() {
+	r.scheduleStop = make(chan struct{})
+	r.scheduleDone = make(chan struct{})
+	go r.runScheduler()
+}
+
+// stopScheduler stops the scheduler goroutine, if it was started, and waits
+// for it to exit. It must not be called with r.mu held.
+func (r *Roller) stopScheduler() {
+	if r.scheduleStop == nil {
+		return
+	}
+	r.stopSchedule.Do(func() {
+		close(r.scheduleStop)
+	})
+	<-r.scheduleDone
+}
+
+// runScheduler waits for each deadline of the rotation policy and rotates the
+// log file once it has passed. If the rotation doesn't happen, e.g. because it
+// failed, it is retried with a growing backoff. It returns when the scheduler
+// is stopped or the policy has no more deadlines.
+func (r *Roller) runScheduler() {
+	defer close(r.scheduleDone)
+	backoff := time.Duration(0)
+	for {
+		r.mu.Lock()
+		opened := r.opened
+		deadline := r.policy.NextDeadline(opened)
+		r.mu.Unlock()
+		if deadline.IsZero() {
+			return
+		}
+
+		// don't spin if the clock lags behind the timer
+		wait := deadline.Sub(currentTime())
+		if wait < minScheduleWait {
+			wait = minScheduleWait
+		}
+		if wait < backoff {
+			wait = backoff
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-r.scheduleStop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// buffered writes belong to the period ending
+		r.flush()
+		r.mu.Lock()
+		r.scheduledRotate()
+		// the deadline stays in the past until the log file is rotated
+		missed := r.opened.Equal(opened) && !currentTime().Before(deadline)
+		r.mu.Unlock()
+		switch {
+		case !missed:
+			backoff = 0
+		case backoff == 0:
+			backoff = 2 * minScheduleWait
+		case backoff < maxScheduleBackoff:
+			backoff *= 2
+			if backoff > maxScheduleBackoff {
+				backoff = maxScheduleBackoff
+			}
+		}
+	}
+}
+
+// scheduledRotate rotates the log file if the rotation policy says so. If the
+// log file is empty and skipEmpty is set, it is kept and taken to cover the new
+// period instead.
+func (r *Roller) scheduledRotate() {
+	if r.file == nil || !r.policy.ShouldRotate(r.state(0)) {
+		return
+	}
+	if r.skipEmpty && r.size == 0 {
+		r.opened = currentTime()
+		return
+	}
+	// what am I going to do, log this?
+	_ = r.rotate()
+}
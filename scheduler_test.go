@@ -0,0 +1,122 @@
+package lumberjack
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduledRotate(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 10, 18, 10, 59, 59, 900*int(time.Millisecond), time.UTC)
+	dir := makeTempDir("TestScheduledRotate", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	r, err := NewRoller(filename, &Options{
+		RotateType:      RotateHourly,
+		ScheduledRotate: true,
+	})
+	isNil(err, t)
+	defer r.Close()
+
+	b := []byte("boo!")
+	n, err := r.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+
+	// nothing is written after the hour is over
+	fakeCurrentTime = time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)
+	<-time.After(300 * time.Millisecond)
+
+	existsWithContent(filepath.Join(dir, "foobar-20261018-10.log"), b, t)
+	existsWithContent(filename, []byte{}, t)
+	fileCount(dir, 2, t)
+}
+
+func TestScheduledRotateSkipEmpty(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 10, 18, 10, 59, 59, 900*int(time.Millisecond), time.UTC)
+	dir := makeTempDir("TestScheduledRotateSkipEmpty", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	r, err := NewRoller(filename, &Options{
+		RotateType:      RotateHourly,
+		ScheduledRotate: true,
+		SkipEmptyRotate: true,
+	})
+	isNil(err, t)
+	defer r.Close()
+
+	fakeCurrentTime = time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)
+	<-time.After(300 * time.Millisecond)
+
+	// the empty file is kept for the new hour
+	existsWithContent(filename, []byte{}, t)
+	fileCount(dir, 1, t)
+
+	b := []byte("boo!")
+	n, err := r.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	existsWithContent(filename, b, t)
+	fileCount(dir, 1, t)
+}
+
+func TestScheduledRotateStoppedByClose(t *testing.T) {
+	currentTime = fakeTime
+	fakeCurrentTime = time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC)
+	dir := makeTempDir("TestScheduledRotateStoppedByClose", t)
+	defer os.RemoveAll(dir)
+
+	r, err := NewRoller(logFile(dir), &Options{
+		RotateType:      RotateDaily,
+		ScheduledRotate: true,
+	})
+	isNil(err, t)
+
+	done := make(chan error)
+	go func() { done <- r.Close() }()
+	select {
+	case err := <-done:
+		isNil(err, t)
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't stop the scheduler")
+	}
+	// closing again is fine
+	isNil(r.Close(), t)
+}
+
+// stuckPolicy has a deadline which has always passed, but never rotates, like
+// a rotation which keeps failing. It counts how often it is asked.
+type stuckPolicy struct{ asked *int64 }
+
+func (stuckPolicy) ShouldRotate(s RotationState) bool { return false }
+func (p stuckPolicy) NextDeadline(now time.Time) time.Time {
+	atomic.AddInt64(p.asked, 1)
+	return now
+}
+func (stuckPolicy) BackupTimestamp(s RotationState) time.Time { return s.Now }
+
+func TestScheduledRotateBackoff(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestScheduledRotateBackoff", t)
+	defer os.RemoveAll(dir)
+
+	var asked int64
+	r, err := NewRoller(logFile(dir), &Options{
+		RotationPolicy:  stuckPolicy{&asked},
+		ScheduledRotate: true,
+	})
+	isNil(err, t)
+	defer r.Close()
+
+	// retried after 20, 40, 80 and 160ms, instead of every 10ms
+	<-time.After(300 * time.Millisecond)
+	if n := atomic.LoadInt64(&asked); n > 10 {
+		t.Fatalf("the scheduler checked %d times in 300ms", n)
+	}
+}
@@ -0,0 +1,77 @@
+package lumberjack
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// throttle limits the rate at which backups are read for compression, shared
+// by all compression workers of a Roller.
+type throttle struct {
+	mu sync.Mutex
+	// rate is the limit in bytes per second.
+	rate int64
+	// next is when the bytes read so far are paid for.
+	next time.Time
+}
+
+func newThrottle(rate int64) *throttle {
+	if rate <= 0 {
+		return nil
+	}
+	return &throttle{rate: rate}
+}
+
+// wait waits until n more bytes may be read, or until ctx is done.
+func (t *throttle) wait(ctx context.Context, n int) error {
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	d := t.next.Sub(now)
+	t.next = t.next.Add(time.Duration(int64(n) * int64(time.Second) / t.rate))
+	t.mu.Unlock()
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttleChunk is the most a throttled read reads at once, so that the rate
+// is kept smoothly.
+const throttleChunk = 32 * 1024
+
+// maintenanceReader reads a backup for compression, at most at the rate of
+// its throttle, if any, and failing once ctx is done.
+type maintenanceReader struct {
+	ctx      context.Context
+	r        io.Reader
+	throttle *throttle
+}
+
+func (m *maintenanceReader) Read(p []byte) (int, error) {
+	if err := m.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if m.throttle == nil {
+		return m.r.Read(p)
+	}
+	if len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := m.r.Read(p)
+	if errWait := m.throttle.wait(m.ctx, n); err == nil {
+		err = errWait
+	}
+	return n, err
+}
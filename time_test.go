@@ -16,6 +16,78 @@ func TestTimeFormat(t *testing.T) {
 	}
 }
 
+func TestCalRemainSecondsDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+	defer func(l *time.Location) { time.Local = l }(time.Local)
+	time.Local = loc
+
+	// 2026-03-08 has 23 hours, 2026-11-01 has 25
+	tests := []struct {
+		now        time.Time
+		rotateType RotateType
+		rotateTime int
+		want       time.Time
+	}{
+		{time.Date(2026, 3, 7, 23, 30, 0, 0, loc), RotateDaily, 1, time.Date(2026, 3, 8, 0, 0, 0, 0, loc)},
+		{time.Date(2026, 3, 8, 0, 30, 0, 0, loc), RotateDaily, 1, time.Date(2026, 3, 9, 0, 0, 0, 0, loc)},
+		{time.Date(2026, 10, 31, 23, 30, 0, 0, loc), RotateDaily, 2, time.Date(2026, 11, 2, 0, 0, 0, 0, loc)},
+		{time.Date(2026, 11, 1, 23, 30, 0, 0, loc), RotateDaily, 1, time.Date(2026, 11, 2, 0, 0, 0, 0, loc)},
+		{time.Date(2026, 3, 7, 23, 30, 0, 0, loc), RotateHourly, 6, time.Date(2026, 3, 8, 0, 0, 0, 0, loc)},
+		{time.Date(2026, 3, 8, 1, 30, 0, 0, loc), RotateHourly, 6, time.Date(2026, 3, 8, 6, 0, 0, 0, loc)},
+	}
+	for _, test := range tests {
+		s := calRemainderSecondToNextRotateTime(test.now, test.rotateType, test.rotateTime, true)
+		if s != test.want.Unix()-test.now.Unix() {
+			t.Error("calRemainderSecondToNextRotateTime failed", test.now, test.rotateType, s, test.want.Unix()-test.now.Unix())
+		}
+	}
+}
+
+func TestCalRemainSecondsWeekly(t *testing.T) {
+	// 2026-10-14 is a Wednesday
+	n := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		weekStart  time.Weekday
+		rotateTime int
+		want       time.Time
+	}{
+		{time.Sunday, 1, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{time.Monday, 1, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{time.Wednesday, 1, time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC)},
+		{time.Thursday, 1, time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)},
+		{time.Monday, 2, time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		s := calRemainderSecondToNextRotateTime(n, RotateWeekly, test.rotateTime, false, test.weekStart)
+		if s != test.want.Unix()-n.Unix() {
+			t.Error("calRemainderSecondToNextRotateTime failed", test.weekStart, test.rotateTime, s, test.want.Unix()-n.Unix())
+		}
+	}
+}
+
+func TestCalRemainSecondsMonthly(t *testing.T) {
+	tests := []struct {
+		now        time.Time
+		rotateTime int
+		want       time.Time
+	}{
+		{time.Date(2026, 1, 31, 10, 0, 0, 0, time.UTC), 1, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), 1, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2024, 2, 29, 23, 0, 0, 0, time.UTC), 1, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 12, 15, 0, 0, 0, 0, time.UTC), 1, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 11, 30, 0, 0, 0, 0, time.UTC), 3, time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		s := calRemainderSecondToNextRotateTime(test.now, RotateMonthly, test.rotateTime, false)
+		if s != test.want.Unix()-test.now.Unix() {
+			t.Error("calRemainderSecondToNextRotateTime failed", test.now, test.rotateTime, s, test.want.Unix()-test.now.Unix())
+		}
+	}
+}
+
 func getZoneDifferentSeconds(now time.Time) int64 {
 	now, _ = time.ParseInLocation("2006-01-02 15:04:05", now.Format("2006-01-02 15:04:05"), time.Local)
 	utcTime, _ := time.ParseInLocation("2006-01-02 15:04:05", now.UTC().Format("2006-01-02 15:04:05"), time.Local)